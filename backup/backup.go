@@ -0,0 +1,259 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/buffer"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+const MarkerSize = 8
+const Marker = "PhyBkup\x00"
+
+// Version Current version of the backup format. Older versions are accepted on load.
+const Version = 1
+
+// HeaderSize Size of the fixed header for Version 1, before Data
+const HeaderSize = MarkerSize + 4 + 4 + 8 + 4 + 4 + 4*3 + SerialNumberSize + 4 + 4 + 4
+
+const SerialNumberSize = 32
+
+const (
+	FlagDeviceId = 1 << iota
+)
+
+// Backup Contents of a flash area, as read via RD_FLASH_AREA, along with the identity of the device
+//
+// Binary layout is as follows, all values are little endian:
+//
+//	Marker          [8]byte
+//	Version         uint32
+//	HeaderSize      uint32
+//	Time            int64, unix seconds
+//	Area            uint32, firmware.FlashArea
+//	Flags           uint32
+//	DeviceId        [3]uint32, valid if Flags has FlagDeviceId
+//	SerialNumber    [32]byte, zero terminated if shorter
+//	PublicSignature uint32
+//	StructLen       uint32
+//	DataSize        uint32
+//	Data            [DataSize]byte
+//	CRC32           uint32, CRC of all preceding bytes
+type Backup struct {
+	Version  uint32
+	Time     time.Time
+	Area     firmware.FlashArea
+	DeviceId *encryption.DeviceId
+
+	// SerialNumber Product serial number of the device the area was read from. Truncated to SerialNumberSize bytes on encoding
+	SerialNumber string
+
+	FlashArea firmware.FlashAreaData
+}
+
+// NewBackup Creates a backup of area at the current time. If area is firmware.FlashAreaPublicAPI, SerialNumber is taken from it
+func NewBackup(area firmware.FlashArea, data *firmware.FlashAreaData, deviceId *encryption.DeviceId) *Backup {
+	b := &Backup{
+		Version: Version,
+		Time:    time.Now().UTC().Truncate(time.Second),
+		Area:    area,
+		FlashArea: firmware.FlashAreaData{
+			PublicSignature: data.PublicSignature,
+			StructLen:       data.StructLen,
+			Data:            slices.Clone(data.Data),
+		},
+	}
+	if deviceId != nil {
+		id := *deviceId
+		b.DeviceId = &id
+	}
+
+	if area == firmware.FlashAreaPublicAPI {
+		if api := b.FlashArea.PublicAPI(); api != nil {
+			b.SerialNumber = string(zeroTerminatedSlice(api.ProductSerialNumber[:]))
+		}
+	}
+
+	return b
+}
+
+// PublicAPI Decodes the stored area. Returns nil if it is not firmware.FlashAreaPublicAPI or too short
+func (b *Backup) PublicAPI() *firmware.FlashAreaData_PublicAPI {
+	if b.Area != firmware.FlashAreaPublicAPI {
+		return nil
+	}
+	return b.FlashArea.PublicAPI()
+}
+
+// Bytes Encodes the backup in the current Version
+func (b *Backup) Bytes() []byte {
+	buf := make([]byte, 0, HeaderSize+len(b.FlashArea.Data)+4)
+	buf = append(buf, Marker...)
+	buf = binary.LittleEndian.AppendUint32(buf, Version)
+	buf = binary.LittleEndian.AppendUint32(buf, HeaderSize)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(b.Time.Unix()))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(b.Area))
+
+	var flags uint32
+	var deviceId encryption.DeviceId
+	if b.DeviceId != nil {
+		flags |= FlagDeviceId
+		deviceId = *b.DeviceId
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, flags)
+	for _, v := range deviceId {
+		buf = binary.LittleEndian.AppendUint32(buf, v)
+	}
+
+	var serialNumber [SerialNumberSize]byte
+	copy(serialNumber[:], b.SerialNumber)
+	buf = append(buf, serialNumber[:]...)
+
+	buf = binary.LittleEndian.AppendUint32(buf, b.FlashArea.PublicSignature)
+	buf = binary.LittleEndian.AppendUint32(buf, b.FlashArea.StructLen)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(b.FlashArea.Data)))
+	buf = append(buf, b.FlashArea.Data...)
+
+	return binary.LittleEndian.AppendUint32(buf, crc.CalculateCRC(buf))
+}
+
+// WriteTo Writes Bytes to w
+func (b *Backup) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// Save Writes the backup to a file at path
+func (b *Backup) Save(path string) error {
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+func LoadBackup(buf []byte) (*Backup, error) {
+	if len(buf) < MarkerSize+4+4+4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if bytes.Compare(buf[:MarkerSize], []byte(Marker)) != 0 {
+		return nil, errors.New("unsupported header")
+	}
+
+	dataSize := len(buf) - 4
+	if expected, calculated := binary.LittleEndian.Uint32(buf[dataSize:]), crc.CalculateCRC(buf[:dataSize]); expected != calculated {
+		return nil, fmt.Errorf("backup CRC not matching: expected %08x, got %08x", expected, calculated)
+	}
+
+	b := &Backup{}
+	dataBuf := buffer.Buffer(buf[MarkerSize:dataSize])
+
+	var err error
+	b.Version, err = dataBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	if b.Version == 0 || b.Version > Version {
+		return nil, fmt.Errorf("unsupported version %d", b.Version)
+	}
+
+	headerSize, err := dataBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	if headerSize < HeaderSize || int(headerSize) > dataSize {
+		return nil, errors.New("invalid header size")
+	}
+
+	// Later versions may extend the header, skip over what is not known
+	headerBuf := dataBuf[:headerSize-MarkerSize-8]
+	dataBuf = dataBuf[headerSize-MarkerSize-8:]
+
+	unixTime, err := headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	unixTimeHigh, err := headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	b.Time = time.Unix(int64(uint64(unixTime)|uint64(unixTimeHigh)<<32), 0).UTC()
+
+	area, err := headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	b.Area = firmware.FlashArea(area)
+
+	flags, err := headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	var deviceId encryption.DeviceId
+	for i := range deviceId {
+		deviceId[i], err = headerBuf.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flags&FlagDeviceId > 0 {
+		b.DeviceId = &deviceId
+	}
+
+	var serialNumber [SerialNumberSize]byte
+	if _, err = headerBuf.Read(serialNumber[:]); err != nil {
+		return nil, err
+	}
+	b.SerialNumber = string(zeroTerminatedSlice(serialNumber[:]))
+
+	b.FlashArea.PublicSignature, err = headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	b.FlashArea.StructLen, err = headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	size, err := headerBuf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	if int(size) != len(dataBuf) {
+		return nil, errors.New("data size not matching")
+	}
+	b.FlashArea.Data = slices.Clone(dataBuf)
+
+	return b, nil
+}
+
+// ReadBackup Reads a whole backup from r
+func ReadBackup(r io.Reader) (*Backup, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return LoadBackup(buf)
+}
+
+// OpenBackup Reads a backup from a file at path
+func OpenBackup(path string) (*Backup, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadBackup(buf)
+}
+
+func zeroTerminatedSlice(data []byte) []byte {
+	if i := slices.Index(data, 0); i >= 0 {
+		return data[:i]
+	}
+	return data
+}
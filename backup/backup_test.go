@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"testing"
+)
+
+func sampleFlashArea(t *testing.T, calibrationBP0 uint32) *firmware.FlashAreaData {
+	var api firmware.FlashAreaData_PublicAPI
+	copy(api.ProductSerialNumber[:], "RC-102-000123")
+	copy(api.ProductName[:], "RadiaCode-102")
+	api.CalibrationBP0 = calibrationBP0
+	api.CalibrationBP290 = 0x3f8ccccd
+
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.LittleEndian, &api); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte{1, 2, 3, 4})
+
+	return &firmware.FlashAreaData{
+		PublicSignature: firmware.FlashAreaPublicSignature,
+		StructLen:       uint32(buf.Len() + 8),
+		Data:            buf.Bytes(),
+	}
+}
+
+func TestBackup_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	deviceId := encryption.DeviceId{0x003B0056, 0x4D4B5002, 0x20323455}
+	b := NewBackup(firmware.FlashAreaPublicAPI, sampleFlashArea(t, 0x3f800000), &deviceId)
+	if b.SerialNumber != "RC-102-000123" {
+		t.Fatalf("unexpected serial number %q", b.SerialNumber)
+	}
+
+	b2, err := LoadBackup(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if differences := Diff(b, b2); len(differences) != 0 {
+		t.Fatalf("unexpected differences %v", differences)
+	}
+	if !b.Time.Equal(b2.Time) {
+		t.Fatalf("time does not match, %s != %s", b.Time, b2.Time)
+	}
+	if b2.PublicAPI().CalibrationBP0 != 0x3f800000 {
+		t.Fatal("calibration does not match")
+	}
+}
+
+func TestBackup_FullSerialNumber(t *testing.T) {
+	t.Parallel()
+
+	// Serial number fills the whole field, without terminator
+	area := sampleFlashArea(t, 0x3f800000)
+	serialNumber := "RC-102-000123-0123456789ABCDEFGH"
+	// ProductSerialNumber follows BootSignature
+	copy(area.Data[4:], serialNumber)
+
+	b := NewBackup(firmware.FlashAreaPublicAPI, area, nil)
+	if b.SerialNumber != serialNumber {
+		t.Fatalf("unexpected serial number %q", b.SerialNumber)
+	}
+
+	b2, err := LoadBackup(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b2.SerialNumber != serialNumber {
+		t.Fatalf("unexpected serial number %q", b2.SerialNumber)
+	}
+}
+
+func TestBackup_BrokenCRC(t *testing.T) {
+	t.Parallel()
+
+	buf := NewBackup(firmware.FlashAreaPublicAPI, sampleFlashArea(t, 0x3f800000), nil).Bytes()
+	buf[HeaderSize] ^= 1
+
+	if _, err := LoadBackup(buf); err == nil {
+		t.Fatal("expected CRC error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	a := NewBackup(firmware.FlashAreaPublicAPI, sampleFlashArea(t, 0x3f800000), nil)
+	b := NewBackup(firmware.FlashAreaPublicAPI, sampleFlashArea(t, 0x3f810000), nil)
+	b.FlashArea.Data[len(b.FlashArea.Data)-1] = 0xff
+
+	differences := Diff(a, b)
+	for _, d := range differences {
+		t.Log(d)
+	}
+
+	if len(differences) != 2 || differences[0].Field != "CalibrationBP0" || differences[1].A != "04" || differences[1].B != "ff" {
+		t.Fatal("unexpected differences")
+	}
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"encoding/binary"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"reflect"
+)
+
+type Difference struct {
+	Field string
+	A, B  string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.A, d.B)
+}
+
+// Diff Compares identity and area contents of two backups.
+// Known areas are compared field by field, remaining data is compared as byte ranges
+func Diff(a, b *Backup) (result []Difference) {
+	add := func(field string, valueA, valueB any) {
+		if !reflect.DeepEqual(valueA, valueB) {
+			result = append(result, Difference{
+				Field: field,
+				A:     formatValue(reflect.ValueOf(valueA)),
+				B:     formatValue(reflect.ValueOf(valueB)),
+			})
+		}
+	}
+
+	add("SerialNumber", a.SerialNumber, b.SerialNumber)
+	add("DeviceId", a.DeviceId, b.DeviceId)
+	add("Area", a.Area, b.Area)
+	add("PublicSignature", a.FlashArea.PublicSignature, b.FlashArea.PublicSignature)
+	add("StructLen", a.FlashArea.StructLen, b.FlashArea.StructLen)
+
+	dataOffset := 0
+	if apiA, apiB := a.PublicAPI(), b.PublicAPI(); apiA != nil && apiB != nil {
+		valueA, valueB := reflect.ValueOf(*apiA), reflect.ValueOf(*apiB)
+		for i := 0; i < valueA.NumField(); i++ {
+			add(valueA.Type().Field(i).Name, valueA.Field(i).Interface(), valueB.Field(i).Interface())
+		}
+		dataOffset = binary.Size(firmware.FlashAreaData_PublicAPI{})
+	}
+
+	dataA, dataB := a.FlashArea.Data[min(dataOffset, len(a.FlashArea.Data)):], b.FlashArea.Data[min(dataOffset, len(b.FlashArea.Data)):]
+	for start := 0; start < max(len(dataA), len(dataB)); {
+		if start < len(dataA) && start < len(dataB) && dataA[start] == dataB[start] {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < max(len(dataA), len(dataB)) && !(end < len(dataA) && end < len(dataB) && dataA[end] == dataB[end]) {
+			end++
+		}
+		result = append(result, Difference{
+			Field: fmt.Sprintf("Data[0x%x:0x%x]", dataOffset+start, dataOffset+end),
+			A:     fmt.Sprintf("%x", dataA[min(start, len(dataA)):min(end, len(dataA))]),
+			B:     fmt.Sprintf("%x", dataB[min(start, len(dataB)):min(end, len(dataB))]),
+		})
+		start = end
+	}
+
+	return result
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "<none>"
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "<none>"
+		}
+		return formatValue(v.Elem())
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(buf), v)
+			return fmt.Sprintf("%q", zeroTerminatedSlice(buf))
+		}
+		return fmt.Sprintf("%08x", v.Interface())
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("0x%x", v.Uint())
+	default:
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/backup"
+)
+
+func init() {
+	commands["backup-info"] = command{
+		Usage: "<backup>",
+		Run:   backupInfo,
+	}
+	commands["backup-diff"] = command{
+		Usage: "<backup A> <backup B>",
+		Run:   backupDiff,
+	}
+}
+
+func backupInfo(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected one backup file")
+	}
+
+	profile, err := keyProfile()
+	if err != nil {
+		return err
+	}
+
+	b, err := backup.OpenBackup(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Version: %d\n", b.Version)
+	fmt.Printf("Time: %s\n", b.Time)
+	fmt.Printf("Serial Number: %q\n", b.SerialNumber)
+	if b.DeviceId != nil {
+		fmt.Printf("Device Id: %08x %08x %08x\n", b.DeviceId[0], b.DeviceId[1], b.DeviceId[2])
+		fmt.Printf("Device Key (%s): %x\n", profile.Name, *profile.DeviceMangleKeyOffset6(*b.DeviceId))
+		fmt.Printf("Device Code Key (%s): %x\n", profile.Name, *profile.DeviceMangleKeyOffset0(*b.DeviceId))
+	}
+	fmt.Printf("Area: %d, %d bytes\n", b.Area, len(b.FlashArea.Data))
+	if api := b.PublicAPI(); api != nil {
+		fmt.Printf("CalibrationBP0: 0x%08x\n", api.CalibrationBP0)
+		fmt.Printf("CalibrationBP290: 0x%08x\n", api.CalibrationBP290)
+	}
+
+	return nil
+}
+
+func backupDiff(args []string) error {
+	if len(args) != 2 {
+		return errors.New("expected two backup files")
+	}
+
+	a, err := backup.OpenBackup(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := backup.OpenBackup(args[1])
+	if err != nil {
+		return err
+	}
+
+	differences := backup.Diff(a, b)
+	for _, d := range differences {
+		fmt.Println(d)
+	}
+
+	if len(differences) > 0 {
+		return errors.New("backups differ")
+	}
+	return nil
+}
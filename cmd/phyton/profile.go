@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"os"
+)
+
+var profileName = flag.String("profile", encryption.DefaultKeyProfileName, "Key profile used by firmware-code, and for device keys in backup-info. backup-diff compares files only")
+var profilesPath = flag.String("profiles", "", "JSON file with additional key profiles")
+
+func init() {
+	commands["profiles"] = command{
+		Usage: "[name]",
+		Run:   profilesList,
+	}
+	commands["firmware-code"] = command{
+		Usage: "<firmware> <output>",
+		Run:   firmwareCode,
+	}
+}
+
+// keyProfile Loads -profiles if set, and returns the profile selected by -profile
+func keyProfile() (*encryption.KeyProfile, error) {
+	if *profilesPath != "" {
+		if err := encryption.LoadKeyProfilesFile(*profilesPath); err != nil {
+			return nil, err
+		}
+	}
+	return encryption.GetKeyProfile(*profileName)
+}
+
+func profilesList(args []string) error {
+	if len(args) > 1 {
+		return errors.New("expected at most one profile name")
+	}
+
+	if len(args) == 0 {
+		if _, err := keyProfile(); err != nil {
+			return err
+		}
+		for _, name := range encryption.KeyProfileNames() {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	*profileName = args[0]
+	profile, err := keyProfile()
+	if err != nil {
+		return err
+	}
+
+	// Output can be used as a template for -profiles
+	buf, err := json.MarshalIndent([]*encryption.KeyProfile{profile}, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(buf))
+	return nil
+}
+
+func firmwareCode(args []string) error {
+	if len(args) != 2 {
+		return errors.New("expected firmware and output files")
+	}
+
+	profile, err := keyProfile()
+	if err != nil {
+		return err
+	}
+
+	buf, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	fw, err := firmware.LoadFirmware(buf)
+	if err != nil {
+		return err
+	}
+	if len(fw.Entries) != 1 {
+		return fmt.Errorf("expected one firmware entry, got %d", len(fw.Entries))
+	}
+	fw.SetKeyProfile(profile)
+
+	code, err := fw.Entries[0].DecryptCode()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(args[1], code, 0o644)
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/icza/bitio"
 )
 
+// ErrOutOfBounds Decompressed output would exceed DataMaxSize
+var ErrOutOfBounds = errors.New("out of bounds output")
+
 func FirmwareBlockDecompress(data []byte) (output []byte, err error) {
 
 	windowIndex := WindowInitialIndex
@@ -33,7 +36,7 @@ func FirmwareBlockDecompress(data []byte) (output []byte, err error) {
 			} else if length, err = r.ReadBits(LengthBits); err != nil {
 				return nil, err
 			} else if length += MaxUncoded; DataMaxSize < (length + uint64(len(output))) {
-				return nil, errors.New("out of bounds output")
+				return nil, ErrOutOfBounds
 			}
 
 			windowIndex, buf = window.GetSet(int(offsetIndex), windowIndex, int(length), windowBuffer)
@@ -42,7 +45,7 @@ func FirmwareBlockDecompress(data []byte) (output []byte, err error) {
 			if literal, err = r.ReadBits(LiteralBits); err != nil {
 				break
 			} else if (DataMaxSize - 1) < len(output) {
-				return nil, errors.New("out of bounds output")
+				return nil, ErrOutOfBounds
 			}
 
 			output = append(output, byte(literal))
@@ -0,0 +1,173 @@
+package databuf
+
+import (
+	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/buffer"
+	"io"
+	"time"
+)
+
+// TimeUnit Resolution of record time offsets
+const TimeUnit = time.Millisecond * 10
+
+type recordDecoder struct {
+	Size   int
+	Decode func(h RecordHeader, buf *buffer.PanicBuffer) Record
+}
+
+var recordDecoders = map[RecordType]recordDecoder{
+	RecordTypeRealTimeData: {4 + 4 + 2 + 2 + 2 + 1, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &RealTimeData{RecordHeader: h}
+		r.CountRate = buf.ReadFloat32()
+		r.DoseRate = buf.ReadFloat32()
+		r.CountRateError = float32(buf.ReadUint16()) / 10
+		r.DoseRateError = float32(buf.ReadUint16()) / 10
+		r.Flags = buf.ReadUint16()
+		r.RealTimeFlags = buf.ReadByte()
+		return r
+	}},
+	RecordTypeRawData: {4 + 4, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &RawData{RecordHeader: h}
+		r.CountRate = buf.ReadFloat32()
+		r.DoseRate = buf.ReadFloat32()
+		return r
+	}},
+	RecordTypeDoseRateDB:   {4 + 4 + 4 + 2 + 2, decodeDoseRateDB},
+	RecordTypeUserData:     {4 + 4 + 4 + 2 + 2, decodeDoseRateDB},
+	RecordTypeScheduleData: {4 + 4 + 4 + 2 + 2, decodeDoseRateDB},
+	RecordTypeRareData: {4 + 4 + 2 + 2 + 2, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &RareData{RecordHeader: h}
+		r.Duration = buf.ReadUint32()
+		r.Dose = buf.ReadFloat32()
+		r.Temperature = (float32(buf.ReadUint16()) - 2000) / 100
+		r.ChargeLevel = float32(buf.ReadUint16()) / 100
+		r.Flags = buf.ReadUint16()
+		return r
+	}},
+	RecordTypeAccelData: {2 + 2 + 2, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &AccelData{RecordHeader: h}
+		r.X = buf.ReadUint16()
+		r.Y = buf.ReadUint16()
+		r.Z = buf.ReadUint16()
+		return r
+	}},
+	RecordTypeEvent: {1 + 1 + 2, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &Event{RecordHeader: h}
+		r.Event = buf.ReadByte()
+		r.EventParam = buf.ReadByte()
+		r.Flags = buf.ReadUint16()
+		return r
+	}},
+	RecordTypeRawCountRate: {4 + 2, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &RawCountRate{RecordHeader: h}
+		r.CountRate = buf.ReadFloat32()
+		r.Flags = buf.ReadUint16()
+		return r
+	}},
+	RecordTypeRawDoseRate: {4 + 2, func(h RecordHeader, buf *buffer.PanicBuffer) Record {
+		r := &RawDoseRate{RecordHeader: h}
+		r.DoseRate = buf.ReadFloat32()
+		r.Flags = buf.ReadUint16()
+		return r
+	}},
+}
+
+func decodeDoseRateDB(h RecordHeader, buf *buffer.PanicBuffer) Record {
+	r := &DoseRateDB{RecordHeader: h}
+	r.Count = buf.ReadUint32()
+	r.CountRate = buf.ReadFloat32()
+	r.DoseRate = buf.ReadFloat32()
+	r.DoseRateError = float32(buf.ReadUint16()) / 10
+	r.Flags = buf.ReadUint16()
+	return r
+}
+
+// Decoder Decodes consecutive DATA_BUF reads, keeping track of sequence numbers across them
+type Decoder struct {
+	// BaseTime Time record offsets are relative to, as set on device
+	BaseTime time.Time
+
+	// Lost Number of records missed, according to sequence number gaps
+	Lost int
+
+	nextSequence int
+}
+
+func NewDecoder(baseTime time.Time) *Decoder {
+	return &Decoder{
+		BaseTime:     baseTime,
+		nextSequence: -1,
+	}
+}
+
+// Decode Decodes all records in data.
+// Unknown record types carry no length, so they are skipped until the next position that starts a known record with a plausible sequence number.
+// On a truncated record, all records before it are returned along with io.ErrUnexpectedEOF
+func (d *Decoder) Decode(data []byte) (records []Record, err error) {
+	buf := buffer.Buffer(data)
+
+	for len(buf) > 0 {
+		if len(buf) < RecordHeaderSize {
+			return records, io.ErrUnexpectedEOF
+		}
+
+		h := RecordHeader{
+			Sequence: buf[0],
+			Type:     RecordType{EventId: buf[1], GroupId: buf[2]},
+			Time:     d.BaseTime.Add(time.Duration(int32(binary.LittleEndian.Uint32(buf[3:]))) * TimeUnit),
+		}
+
+		if d.nextSequence != -1 && int(h.Sequence) != d.nextSequence {
+			d.Lost += int(h.Sequence-uint8(d.nextSequence)) & 0xff
+		}
+		d.nextSequence = int(h.Sequence + 1)
+
+		decoder, ok := recordDecoders[h.Type]
+		if !ok {
+			end := d.resync(buf, RecordHeaderSize)
+			records = append(records, &Unknown{
+				RecordHeader: h,
+				Data:         append([]byte(nil), buf[RecordHeaderSize:end]...),
+			})
+			buf = buf[end:]
+			continue
+		}
+
+		if len(buf) < RecordHeaderSize+decoder.Size {
+			return records, io.ErrUnexpectedEOF
+		}
+
+		recordBuf := buffer.PanicBuffer(buf[RecordHeaderSize : RecordHeaderSize+decoder.Size])
+		records = append(records, decoder.Decode(h, &recordBuf))
+		buf = buf[RecordHeaderSize+decoder.Size:]
+	}
+
+	return records, nil
+}
+
+// ResyncWindow Maximum sequence number gap accepted when resynchronizing after an unknown record
+const ResyncWindow = 16
+
+// resync Finds the offset of the next known record within ResyncWindow of expected sequence number, or the end of buf.
+// Candidates must be followed by either the end of buf or a record with the next sequence number
+func (d *Decoder) resync(buf []byte, offset int) int {
+	for ; offset+RecordHeaderSize <= len(buf); offset++ {
+		if uint8(buf[offset]-uint8(d.nextSequence)) >= ResyncWindow {
+			continue
+		}
+		decoder, ok := recordDecoders[RecordType{EventId: buf[offset+1], GroupId: buf[offset+2]}]
+		if !ok {
+			continue
+		}
+		end := offset + RecordHeaderSize + decoder.Size
+		if end == len(buf) || (end < len(buf) && buf[end] == buf[offset]+1) {
+			return offset
+		}
+	}
+	return len(buf)
+}
+
+// Decode Decodes a single DATA_BUF read. See Decoder.Decode
+func Decode(data []byte, baseTime time.Time) ([]Record, error) {
+	return NewDecoder(baseTime).Decode(data)
+}
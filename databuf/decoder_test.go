@@ -0,0 +1,120 @@
+package databuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func appendRecordHeader(buf []byte, sequence uint8, t RecordType, offset int32) []byte {
+	buf = append(buf, sequence, t.EventId, t.GroupId)
+	return binary.LittleEndian.AppendUint32(buf, uint32(offset))
+}
+
+func appendFloat32(buf []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	t.Parallel()
+
+	var data []byte
+	data = appendRecordHeader(data, 10, RecordTypeRealTimeData, 100)
+	data = appendFloat32(data, 5.5)
+	data = appendFloat32(data, 1e-7)
+	data = binary.LittleEndian.AppendUint16(data, 123)
+	data = binary.LittleEndian.AppendUint16(data, 456)
+	data = binary.LittleEndian.AppendUint16(data, 1)
+	data = append(data, 2)
+
+	// Unknown record, followed by junk
+	data = appendRecordHeader(data, 11, RecordType{1, 3}, 200)
+	data = append(data, 0xff, 0xff, 12, 0xee, 0xee, 0xff)
+
+	// Sequence gap of two records
+	data = appendRecordHeader(data, 12+2, RecordTypeRareData, 300)
+	data = binary.LittleEndian.AppendUint32(data, 3600)
+	data = appendFloat32(data, 1e-6)
+	data = binary.LittleEndian.AppendUint16(data, 2000+2550)
+	data = binary.LittleEndian.AppendUint16(data, 9900)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+
+	baseTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	decoder := NewDecoder(baseTime)
+	records, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	realTime, ok := records[0].(*RealTimeData)
+	if !ok || realTime.CountRate != 5.5 || realTime.CountRateError != 12.3 || realTime.RealTimeFlags != 2 || !realTime.Time.Equal(baseTime.Add(time.Second)) {
+		t.Fatalf("unexpected record %+v", records[0])
+	}
+
+	if unknown, ok := records[1].(*Unknown); !ok || len(unknown.Data) != 6 {
+		t.Fatalf("unexpected record %+v", records[1])
+	}
+
+	rare, ok := records[2].(*RareData)
+	if !ok || rare.Duration != 3600 || rare.Temperature != 25.5 || rare.ChargeLevel != 99 || rare.Header().Sequence != 14 {
+		t.Fatalf("unexpected record %+v", records[2])
+	}
+
+	if decoder.Lost != 2 {
+		t.Fatalf("expected 2 lost records, got %d", decoder.Lost)
+	}
+
+	records, err = Decode(data[:RecordHeaderSize+4], baseTime)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(records) != 0 {
+		t.Fatalf("expected truncated record, got %v", err)
+	}
+}
+
+func TestDecodeSpectrum(t *testing.T) {
+	t.Parallel()
+
+	var data []byte
+	data = binary.LittleEndian.AppendUint32(data, 60)
+	data = appendFloat32(data, -5)
+	data = appendFloat32(data, 2.5)
+	data = appendFloat32(data, 0.0004)
+
+	// two zeros, absolute, then deltas of each size
+	data = binary.LittleEndian.AppendUint16(data, 2<<4|0)
+	data = binary.LittleEndian.AppendUint16(data, 1<<4|1)
+	data = append(data, 200)
+	data = binary.LittleEndian.AppendUint16(data, 1<<4|2)
+	data = append(data, 0xf6)
+	data = binary.LittleEndian.AppendUint16(data, 1<<4|3)
+	data = binary.LittleEndian.AppendUint16(data, 1000)
+	data = binary.LittleEndian.AppendUint16(data, 1<<4|4)
+	data = append(data, 0x00, 0x00, 0x01)
+	data = binary.LittleEndian.AppendUint16(data, 1<<4|5)
+	data = binary.LittleEndian.AppendUint32(data, uint32(0xffffffff))
+
+	s, err := DecodeSpectrum(data, SpectrumFormatDelta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []uint32{0, 0, 200, 190, 1190, 1190 + 0x10000, 1190 + 0x10000 - 1}
+	if len(s.Counts) != len(expected) {
+		t.Fatalf("unexpected counts %v", s.Counts)
+	}
+	for i := range expected {
+		if s.Counts[i] != expected[i] {
+			t.Fatalf("unexpected counts %v", s.Counts)
+		}
+	}
+
+	if s.Duration != time.Minute || math.Abs(s.Energy(10)-(-5+25+0.04)) > 1e-6 {
+		t.Fatalf("unexpected spectrum %+v", s)
+	}
+}
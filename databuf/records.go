@@ -0,0 +1,122 @@
+package databuf
+
+import (
+	"time"
+)
+
+type RecordType struct {
+	EventId uint8
+	GroupId uint8
+}
+
+var (
+	RecordTypeRealTimeData = RecordType{0, 0}
+	RecordTypeRawData      = RecordType{0, 1}
+	RecordTypeDoseRateDB   = RecordType{0, 2}
+	RecordTypeRareData     = RecordType{0, 3}
+	RecordTypeUserData     = RecordType{0, 4}
+	RecordTypeScheduleData = RecordType{0, 5}
+	RecordTypeAccelData    = RecordType{0, 6}
+	RecordTypeEvent        = RecordType{0, 7}
+	RecordTypeRawCountRate = RecordType{0, 8}
+	RecordTypeRawDoseRate  = RecordType{0, 9}
+)
+
+// RecordHeaderSize Size of RecordHeader as encoded
+//
+//	Sequence   uint8
+//	EventId    uint8
+//	GroupId    uint8
+//	TimeOffset int32, in units of 10ms from the buffer base time
+const RecordHeaderSize = 1 + 1 + 1 + 4
+
+type RecordHeader struct {
+	Sequence uint8
+	Type     RecordType
+	Time     time.Time
+}
+
+type Record interface {
+	Header() RecordHeader
+}
+
+func (h RecordHeader) Header() RecordHeader {
+	return h
+}
+
+// RealTimeData Averaged count and dose rate, as shown on device display
+type RealTimeData struct {
+	RecordHeader
+	// CountRate counts per second
+	CountRate float32
+	// CountRateError relative, in percent
+	CountRateError float32
+	// DoseRate Sv/h
+	DoseRate float32
+	// DoseRateError relative, in percent
+	DoseRateError float32
+	Flags         uint16
+	RealTimeFlags uint8
+}
+
+// RawData Instant count and dose rate
+type RawData struct {
+	RecordHeader
+	CountRate float32
+	DoseRate  float32
+}
+
+// DoseRateDB Dose rate history entry. Also used by RecordTypeUserData and RecordTypeScheduleData
+type DoseRateDB struct {
+	RecordHeader
+	Count     uint32
+	CountRate float32
+	DoseRate  float32
+	// DoseRateError relative, in percent
+	DoseRateError float32
+	Flags         uint16
+}
+
+// RareData Slow changing device state
+type RareData struct {
+	RecordHeader
+	// Duration of accumulated dose, in seconds
+	Duration uint32
+	// Dose Sv
+	Dose float32
+	// Temperature Celsius
+	Temperature float32
+	// ChargeLevel in percent
+	ChargeLevel float32
+	Flags       uint16
+}
+
+type AccelData struct {
+	RecordHeader
+	X, Y, Z uint16
+}
+
+type Event struct {
+	RecordHeader
+	Event      uint8
+	EventParam uint8
+	Flags      uint16
+}
+
+type RawCountRate struct {
+	RecordHeader
+	CountRate float32
+	Flags     uint16
+}
+
+type RawDoseRate struct {
+	RecordHeader
+	DoseRate float32
+	Flags    uint16
+}
+
+// Unknown Record of unknown type. Data contains the bytes skipped until the next valid record
+type Unknown struct {
+	RecordHeader
+	Data []byte
+}
@@ -0,0 +1,123 @@
+package databuf
+
+import (
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/buffer"
+	"time"
+)
+
+const (
+	// SpectrumFormatRaw Counts are stored as uint32 per channel
+	SpectrumFormatRaw = 0
+	// SpectrumFormatDelta Counts are stored in runs of delta coded values
+	SpectrumFormatDelta = 1
+)
+
+// Spectrum Snapshot of accumulated spectrum, as read from device
+type Spectrum struct {
+	// Duration Accumulation time
+	Duration time.Duration
+
+	// Calibration Energy calibration coefficients, E(channel) = A0 + A1 * channel + A2 * channel^2 in keV
+	Calibration [3]float32
+
+	Counts []uint32
+}
+
+// Energy Returns energy in keV at the channel
+func (s *Spectrum) Energy(channel float64) float64 {
+	return float64(s.Calibration[0]) + float64(s.Calibration[1])*channel + float64(s.Calibration[2])*channel*channel
+}
+
+// DecodeSpectrum Decodes a spectrum snapshot in the given format
+//
+//	Duration    uint32, seconds
+//	Calibration [3]float32
+//	Counts      []byte, see SpectrumFormatRaw and SpectrumFormatDelta
+func DecodeSpectrum(data []byte, format int) (s *Spectrum, err error) {
+	buf := buffer.Buffer(data)
+	s = &Spectrum{}
+
+	duration, err := buf.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	s.Duration = time.Duration(duration) * time.Second
+
+	for i := range s.Calibration {
+		if s.Calibration[i], err = buf.ReadFloat32(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case SpectrumFormatRaw:
+		if len(buf)%4 != 0 {
+			return nil, errors.New("spectrum counts not aligned")
+		}
+		s.Counts = make([]uint32, 0, len(buf)/4)
+		for len(buf) > 0 {
+			v, _ := buf.ReadUint32()
+			s.Counts = append(s.Counts, v)
+		}
+	case SpectrumFormatDelta:
+		if s.Counts, err = decodeDeltaCounts(buf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spectrum format %d", format)
+	}
+
+	return s, nil
+}
+
+// decodeDeltaCounts Each run starts with an uint16, with count in the upper 12 bits and value encoding in the lower 4 bits
+func decodeDeltaCounts(buf buffer.Buffer) (counts []uint32, err error) {
+	var last int32
+	for len(buf) > 0 {
+		var run uint16
+		if run, err = buf.ReadUint16(); err != nil {
+			return nil, err
+		}
+		count, encoding := int(run>>4), run&0xf
+
+		for i := 0; i < count; i++ {
+			var v int32
+			switch encoding {
+			case 0:
+				v = 0
+			case 1:
+				var b uint8
+				b, err = buf.ReadByte()
+				v = int32(b)
+			case 2:
+				var b uint8
+				b, err = buf.ReadByte()
+				v = last + int32(int8(b))
+			case 3:
+				var d int16
+				d, err = buf.ReadInt16()
+				v = last + int32(d)
+			case 4:
+				var b [3]byte
+				if _, err = buf.Read(b[:]); err == nil {
+					v = last + (int32(int8(b[2]))<<16 | int32(b[1])<<8 | int32(b[0]))
+				}
+			case 5:
+				var d int32
+				d, err = buf.ReadInt32()
+				v = last + d
+			default:
+				return nil, fmt.Errorf("unsupported value encoding %d", encoding)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			last = v
+			counts = append(counts, uint32(v))
+		}
+	}
+	return counts, nil
+}
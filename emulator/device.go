@@ -0,0 +1,157 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/protocol"
+	"slices"
+	"sync"
+)
+
+// Device Emulates the bootloader side of the protocol over a virtual Flash.
+// Use with protocol.NewMockTransport to talk to it via protocol.Client
+type Device struct {
+	Flash *Flash
+
+	// Areas Answered via protocol.CommandReadFlashArea
+	Areas map[firmware.FlashArea]*firmware.FlashAreaData
+
+	// Material Used to decrypt firmware blocks. Generator is not used.
+	Material encryption.KeyMaterial
+
+	// Timer Source of RD_FLASH_AREA seeds, emulating TIM6_CNT. If nil, a free-running 16-bit counter is used
+	Timer func() uint32
+
+	lock       sync.Mutex
+	counter    uint16
+	updating   bool
+	compressed bool
+}
+
+func NewDevice(flash *Flash) *Device {
+	return &Device{
+		Flash:    flash,
+		Areas:    make(map[firmware.FlashArea]*firmware.FlashAreaData),
+		Material: encryption.NewFlashKeyMaterial(nil),
+	}
+}
+
+// SetArea Sets area contents, fixing up PublicSignature and StructLen
+func (d *Device) SetArea(area firmware.FlashArea, data []byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.Areas[area] = &firmware.FlashAreaData{
+		PublicSignature: firmware.FlashAreaPublicSignature,
+		StructLen:       uint32(len(data) + 8),
+		Data:            slices.Clone(data),
+	}
+}
+
+func (d *Device) HandleRequest(header protocol.Header, payload []byte) []byte {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	switch header.Command {
+	case protocol.CommandReadFlashArea:
+		return d.readFlashArea(payload)
+	case protocol.CommandFlashUpdateBegin:
+		return statusResponse(d.updateBegin(payload))
+	case protocol.CommandFlashWriteBlock:
+		return statusResponse(d.writeBlock(payload))
+	case protocol.CommandFlashUpdateEnd:
+		return statusResponse(d.updateEnd())
+	default:
+		// Unknown commands are not answered
+		return nil
+	}
+}
+
+func statusResponse(status firmware.FlashStatus) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(status))
+}
+
+func (d *Device) timer() uint32 {
+	if d.Timer != nil {
+		return d.Timer()
+	}
+	d.counter++
+	return uint32(d.counter)
+}
+
+func (d *Device) readFlashArea(payload []byte) []byte {
+	if len(payload) < 4 {
+		return firmware.EncodeReadFlashArea(firmware.FlashInvalidAreaName, nil, 0)
+	}
+
+	area, ok := d.Areas[firmware.FlashArea(binary.LittleEndian.Uint32(payload))]
+	if !ok {
+		return firmware.EncodeReadFlashArea(firmware.FlashInvalidAreaName, nil, 0)
+	} else if area.PublicSignature != firmware.FlashAreaPublicSignature {
+		return firmware.EncodeReadFlashArea(firmware.FlashInvalidSign, nil, 0)
+	}
+
+	return firmware.EncodeReadFlashArea(firmware.FlashOK, area, d.timer())
+}
+
+func (d *Device) updateBegin(payload []byte) firmware.FlashStatus {
+	if len(payload) < 1 {
+		return firmware.FlashInitEr
+	}
+	d.updating = true
+	d.compressed = payload[0] > 0
+	return firmware.FlashOK
+}
+
+func (d *Device) updateEnd() firmware.FlashStatus {
+	if !d.updating {
+		return firmware.FlashInitEr
+	}
+	d.updating = false
+	return firmware.FlashOK
+}
+
+func (d *Device) writeBlock(payload []byte) firmware.FlashStatus {
+	if !d.updating {
+		return firmware.FlashInitEr
+	}
+
+	block, err := decodeBlock(payload)
+	if err != nil {
+		return firmware.FlashSignEr
+	}
+
+	data, status := d.decryptBlock(block)
+	if status != firmware.FlashOK {
+		return status
+	}
+
+	return d.Flash.Write(block.Header.Addr, data)
+}
+
+// decryptBlock Decrypts, decompresses and verifies a block as the bootloader would
+func (d *Device) decryptBlock(block firmware.Block) ([]byte, firmware.FlashStatus) {
+	data, err := firmware.DecryptBlockFlashData(block.Block, block.Header, d.compressed, d.Material)
+	if err != nil {
+		keyBlock := slices.Clone(block.Block.KeyBlock())
+		d.Material.OuterKey().Decrypt(keyBlock)
+		if mangleIndex := encryption.EncryptedBlock(keyBlock).MangleIndex(); mangleIndex > encryption.MangleIndexAlternateKey7 {
+			return nil, firmware.FlashInvalidKeyNumb
+		}
+		return nil, firmware.FlashInvalidCRC
+	}
+
+	return data, firmware.FlashOK
+}
+
+func decodeBlock(payload []byte) (block firmware.Block, err error) {
+	blocks, err := firmware.ParseBlocks(payload)
+	if err != nil {
+		return block, err
+	}
+	if len(blocks) != 1 {
+		return block, fmt.Errorf("expected one block, got %d", len(blocks))
+	}
+	return blocks[0], nil
+}
@@ -0,0 +1,159 @@
+package emulator
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/protocol"
+	"io"
+	"testing"
+)
+
+func newTestClient(device *Device) *protocol.Client {
+	return protocol.NewClient(protocol.NewMockTransport(device))
+}
+
+func execute(t *testing.T, client *protocol.Client, command protocol.Command, payload []byte) firmware.FlashStatus {
+	response, err := client.Execute(command, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return firmware.FlashStatus(binary.LittleEndian.Uint32(response))
+}
+
+func testBlock(t *testing.T, addr uint32, data []byte, compressed bool) firmware.Block {
+	b, header, err := firmware.EncryptBlockData(data, compressed, encryption.NewFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Addr = addr
+
+	return firmware.Block{
+		Header: header,
+		Block:  b,
+	}
+}
+
+func TestDevice_WriteBlock(t *testing.T) {
+	t.Parallel()
+
+	for _, compressed := range []bool{false, true} {
+		device := NewDevice(NewDefaultFlash())
+		client := newTestClient(device)
+
+		data := make([]byte, 0x1000)
+		if _, err := io.ReadFull(rand.Reader, data[:0x100]); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := execute(t, client, protocol.CommandFlashWriteBlock, testBlock(t, firmware.BaseAddress, data, compressed).Bytes()); status != firmware.FlashInitEr {
+			t.Fatalf("expected %s, got %s", firmware.FlashInitEr, status)
+		}
+
+		if status := execute(t, client, protocol.CommandFlashUpdateBegin, []byte{boolToByte(compressed)}); status != firmware.FlashOK {
+			t.Fatal(status)
+		}
+
+		// Write twice, second time over programmed flash
+		for i := 0; i < 2; i++ {
+			if status := execute(t, client, protocol.CommandFlashWriteBlock, testBlock(t, firmware.BaseAddress+0x400, data, compressed).Bytes()); status != firmware.FlashOK {
+				t.Fatal(status)
+			}
+		}
+
+		if status := execute(t, client, protocol.CommandFlashUpdateEnd, nil); status != firmware.FlashOK {
+			t.Fatal(status)
+		}
+
+		flashData, status := device.Flash.Read(firmware.BaseAddress+0x400, len(data))
+		if status != firmware.FlashOK {
+			t.Fatal(status)
+		}
+		if bytes.Compare(flashData, data) != 0 {
+			t.Fatal("flash data does not match")
+		}
+		if before, _ := device.Flash.Read(firmware.BaseAddress, 0x400); bytes.Count(before, []byte{ErasedValue}) != len(before) {
+			t.Fatal("flash before data should be erased")
+		}
+		if device.Flash.IsErased(0) || !device.Flash.IsErased(device.Flash.Sectors()-1) {
+			t.Fatal("unexpected sector erase state")
+		}
+	}
+}
+
+func TestDevice_WriteBlock_Errors(t *testing.T) {
+	t.Parallel()
+
+	device := NewDevice(NewDefaultFlash())
+	device.Flash.SetWriteProtection(1, true)
+	client := newTestClient(device)
+
+	if status := execute(t, client, protocol.CommandFlashUpdateBegin, []byte{0}); status != firmware.FlashOK {
+		t.Fatal(status)
+	}
+
+	data := make([]byte, 64)
+
+	if status := execute(t, client, protocol.CommandFlashWriteBlock, testBlock(t, firmware.BaseAddress+DefaultSectorSize, data, false).Bytes()); status != firmware.FlashWrProt {
+		t.Fatalf("expected %s, got %s", firmware.FlashWrProt, status)
+	}
+
+	if status := execute(t, client, protocol.CommandFlashWriteBlock, testBlock(t, firmware.BaseAddress-0x100, data, false).Bytes()); status != firmware.FlashInvAddr {
+		t.Fatalf("expected %s, got %s", firmware.FlashInvAddr, status)
+	}
+
+	block := testBlock(t, firmware.BaseAddress, data, false)
+	block.Block.DataBlock()[0] ^= 1
+	if status := execute(t, client, protocol.CommandFlashWriteBlock, block.Bytes()); status != firmware.FlashInvalidCRC {
+		t.Fatalf("expected %s, got %s", firmware.FlashInvalidCRC, status)
+	}
+
+	// Malformed payloads: truncated header, truncated data, and a size beyond payload
+	encoded := testBlock(t, firmware.BaseAddress, data, false).Bytes()
+	oversized := binary.LittleEndian.AppendUint32(append([]byte{}, encoded[:4]...), 0xfffffff0^firmware.BlockHeaderSizeKey)
+	for _, payload := range [][]byte{encoded[:6], encoded[:len(encoded)-1], append(oversized, encoded[8:]...)} {
+		if status := execute(t, client, protocol.CommandFlashWriteBlock, payload); status != firmware.FlashSignEr {
+			t.Fatalf("expected %s, got %s", firmware.FlashSignEr, status)
+		}
+	}
+}
+
+func TestDevice_ReadFlashArea(t *testing.T) {
+	t.Parallel()
+
+	device := NewDevice(NewDefaultFlash())
+	device.SetArea(firmware.FlashAreaPublicAPI, []byte("area contents"))
+	client := newTestClient(device)
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Execute(protocol.CommandReadFlashArea, binary.LittleEndian.AppendUint32(nil, uint32(firmware.FlashAreaPublicAPI)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		area, err := firmware.DecodeReadFlashArea(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(area.Data) != "area contents" {
+			t.Fatalf("unexpected area data %q", area.Data)
+		}
+	}
+
+	response, err := client.Execute(protocol.CommandReadFlashArea, binary.LittleEndian.AppendUint32(nil, 1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = firmware.DecodeReadFlashArea(response); err == nil {
+		t.Fatal("expected error on unknown area")
+	}
+}
+
+func boolToByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package emulator
+
+import (
+	"bytes"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"slices"
+)
+
+// ErasedValue Value of flash bytes after erase
+const ErasedValue = 0xFF
+
+// ProgramAlignment STM32L4 programs flash in double words
+const ProgramAlignment = 8
+
+// DefaultSectorSize Page size of STM32L4 flash
+const DefaultSectorSize = 0x800
+
+// DefaultSectors Page count of 256 KiB STM32L475VC flash
+const DefaultSectors = 128
+
+// Flash Virtual STM32 flash memory, organized in sectors
+type Flash struct {
+	Base       uint32
+	SectorSize uint32
+
+	// ReadProtected Reads fail with firmware.FlashRdpErr when set
+	ReadProtected bool
+
+	data           []byte
+	writeProtected []bool
+}
+
+// NewFlash Creates a fully erased flash
+func NewFlash(base, sectorSize uint32, sectors int) *Flash {
+	f := &Flash{
+		Base:           base,
+		SectorSize:     sectorSize,
+		data:           make([]byte, int(sectorSize)*sectors),
+		writeProtected: make([]bool, sectors),
+	}
+	for i := range f.data {
+		f.data[i] = ErasedValue
+	}
+	return f
+}
+
+// NewDefaultFlash Creates a fully erased flash laid out as STM32L475VC
+func NewDefaultFlash() *Flash {
+	return NewFlash(firmware.BaseAddress, DefaultSectorSize, DefaultSectors)
+}
+
+func (f *Flash) Size() int {
+	return len(f.data)
+}
+
+func (f *Flash) Sectors() int {
+	return len(f.writeProtected)
+}
+
+// Sector Returns the sector index containing addr, or -1 if out of range
+func (f *Flash) Sector(addr uint32) int {
+	if addr < f.Base || addr-f.Base >= uint32(len(f.data)) {
+		return -1
+	}
+	return int((addr - f.Base) / f.SectorSize)
+}
+
+func (f *Flash) SetWriteProtection(sector int, protected bool) {
+	f.writeProtected[sector] = protected
+}
+
+func (f *Flash) IsWriteProtected(sector int) bool {
+	return f.writeProtected[sector]
+}
+
+// IsErased Whether the whole sector is in erased state
+func (f *Flash) IsErased(sector int) bool {
+	return bytes.Count(f.sector(sector), []byte{ErasedValue}) == int(f.SectorSize)
+}
+
+func (f *Flash) sector(sector int) []byte {
+	return f.data[uint32(sector)*f.SectorSize : uint32(sector+1)*f.SectorSize]
+}
+
+// checkRange Verifies [addr, addr+size) is in flash and returns the sector range it spans
+func (f *Flash) checkRange(addr uint32, size int) (first, last int, status firmware.FlashStatus) {
+	if size <= 0 {
+		return 0, 0, firmware.FlashInvAddr
+	}
+	first, last = f.Sector(addr), f.Sector(addr+uint32(size)-1)
+	if first == -1 || last == -1 || addr+uint32(size) < addr {
+		return 0, 0, firmware.FlashInvAddr
+	}
+	return first, last, firmware.FlashOK
+}
+
+// Read Returns a copy of [addr, addr+size)
+func (f *Flash) Read(addr uint32, size int) ([]byte, firmware.FlashStatus) {
+	if f.ReadProtected {
+		return nil, firmware.FlashRdpErr
+	}
+	if _, _, status := f.checkRange(addr, size); status != firmware.FlashOK {
+		return nil, status
+	}
+	offset := addr - f.Base
+	return slices.Clone(f.data[offset : offset+uint32(size)]), firmware.FlashOK
+}
+
+// Erase Erases the sector containing addr
+func (f *Flash) Erase(addr uint32) firmware.FlashStatus {
+	sector := f.Sector(addr)
+	if sector == -1 {
+		return firmware.FlashInvAddr
+	} else if f.writeProtected[sector] {
+		return firmware.FlashWrProt
+	}
+
+	buf := f.sector(sector)
+	for i := range buf {
+		buf[i] = ErasedValue
+	}
+	return firmware.FlashOK
+}
+
+// Program Programs data at addr. Target range must be erased, and aligned to ProgramAlignment
+func (f *Flash) Program(addr uint32, data []byte) firmware.FlashStatus {
+	if addr%ProgramAlignment != 0 || len(data)%ProgramAlignment != 0 {
+		return firmware.FlashInvAddr
+	}
+	first, last, status := f.checkRange(addr, len(data))
+	if status != firmware.FlashOK {
+		return status
+	}
+	for sector := first; sector <= last; sector++ {
+		if f.writeProtected[sector] {
+			return firmware.FlashWrProt
+		}
+	}
+
+	offset := addr - f.Base
+	target := f.data[offset : offset+uint32(len(data))]
+	if bytes.Count(target, []byte{ErasedValue}) != len(target) {
+		return firmware.FlashNotBlank
+	}
+
+	copy(target, data)
+
+	if bytes.Compare(target, data) != 0 {
+		return firmware.FlashVerify
+	}
+	return firmware.FlashOK
+}
+
+// Write Programs data at addr, erasing the sectors it spans as needed.
+// Contents of those sectors outside of the written range are preserved.
+func (f *Flash) Write(addr uint32, data []byte) firmware.FlashStatus {
+	if len(data)%ProgramAlignment != 0 {
+		data = append(slices.Clone(data), bytes.Repeat([]byte{ErasedValue}, ProgramAlignment-len(data)%ProgramAlignment)...)
+	}
+
+	first, last, status := f.checkRange(addr, len(data))
+	if status != firmware.FlashOK {
+		return status
+	}
+
+	if status = f.Program(addr, data); status != firmware.FlashNotBlank {
+		return status
+	}
+
+	// Read-modify-write of all spanned sectors
+	start := f.Base + uint32(first)*f.SectorSize
+	contents := slices.Clone(f.data[start-f.Base : start-f.Base+uint32(last-first+1)*f.SectorSize])
+	copy(contents[addr-start:], data)
+
+	for sector := first; sector <= last; sector++ {
+		if status = f.Erase(f.Base + uint32(sector)*f.SectorSize); status != firmware.FlashOK {
+			return status
+		}
+	}
+
+	return f.Program(start, contents)
+}
@@ -0,0 +1,103 @@
+package attack
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"slices"
+)
+
+// alternateKeyBlock Inner key block of an encrypted block, with the outer mangle removed
+type alternateKeyBlock struct {
+	Block encryption.EncryptedBlock
+	// CRCPair Encrypted CRC1, CRC2 as a single Mangle block
+	CRCPair uint64
+}
+
+// SearchAlternateKeyTable Searches space for the alternate keys used by blocks with mangle index within encryption.MangleIndexAlternateKey0 to encryption.MangleIndexAlternateKey7.
+// Only keys contained in space can be found: keys generated from seeds (BorlandRandKeySpace, BorlandRandByteKeySpace),
+// partially known keys (MaskedKeySpace) or candidate lists (ListKeySpace). Arbitrary keys cannot be recovered, see package documentation:
+// blocks only provide a 32-bit check per key, so an unknown table must be read from firmware and given as material.AlternateKeyTable.
+// A candidate key is accepted when CRC1 == CRC2 after decryption of all blocks using its index, which rules out wrong keys with probability 1 - 2^-32 per block.
+// When verifyCrc is set, as with uncompressed blocks, data CRC must also match.
+// The returned table contains found keys, and material.AlternateKeyTable or encryption.AlternateMangleTable entries for the rest, as indicated by found.
+// If any index used by blocks is not found, the error wraps ErrNotInSpace
+func SearchAlternateKeyTable(ctx context.Context, blocks []encryption.EncryptedBlock, material encryption.KeyMaterial, space KeySpace, workers int, verifyCrc bool) (table encryption.MangleKeyTable, found [8]bool, err error) {
+	if material.AlternateKeyTable != nil {
+		table = *material.AlternateKeyTable
+	} else {
+		table = encryption.AlternateMangleTable
+	}
+
+	var groups [8][]alternateKeyBlock
+	for _, b := range blocks {
+		keyBlock := slices.Clone(b.KeyBlock())
+		material.OuterKey().Decrypt(keyBlock)
+
+		mangleIndex := encryption.EncryptedBlock(keyBlock).MangleIndex()
+		if mangleIndex < encryption.MangleIndexAlternateKey0 || mangleIndex > encryption.MangleIndexAlternateKey7 {
+			continue
+		}
+
+		i := mangleIndex - encryption.MangleIndexAlternateKey0
+		groups[i] = append(groups[i], alternateKeyBlock{
+			Block:   b,
+			CRCPair: binary.LittleEndian.Uint64(keyBlock[encryption.EncryptedBlockCRC1Offset:]),
+		})
+	}
+
+	empty := true
+	var missing []int
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		empty = false
+
+		verifyMaterial := material
+		verifyMaterial.AlternateKeyTable = &encryption.MangleKeyTable{}
+
+		var index uint64
+		index, err = Search(ctx, space.Size(), workers, nil, func(index uint64) bool {
+			key := space.Key(index)
+			for _, b := range group {
+				if crcPair := key.DecryptBlock(b.CRCPair); uint32(crcPair) != uint32(crcPair>>32) {
+					return false
+				}
+			}
+
+			if verifyCrc {
+				// Rare, only reached by candidates passing all CRC pair checks
+				table := *verifyMaterial.AlternateKeyTable
+				table[i] = key
+				m := verifyMaterial
+				m.AlternateKeyTable = &table
+				for _, b := range group {
+					if slices.Clone(b.Block).Decrypt(m, true) != nil {
+						return false
+					}
+				}
+			}
+			return true
+		})
+		if errors.Is(err, ErrNotInSpace) {
+			missing = append(missing, i)
+			continue
+		} else if err != nil {
+			return table, found, err
+		}
+
+		table[i] = space.Key(index)
+		found[i] = true
+	}
+
+	if empty {
+		return table, found, errors.New("no blocks using alternate keys")
+	} else if len(missing) > 0 {
+		return table, found, fmt.Errorf("alternate keys %v: %w", missing, ErrNotInSpace)
+	}
+
+	return table, found, nil
+}
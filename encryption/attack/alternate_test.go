@@ -0,0 +1,72 @@
+package attack
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"io"
+	"testing"
+)
+
+func TestSearchAlternateKeyTable(t *testing.T) {
+	t.Parallel()
+
+	seed := randomUint32(t)
+	space := BorlandRandKeySpace{Start: seed - (seed & 0xffff), Count: 1 << 17}
+
+	// Alternate keys generated by a device from seeds within the space, and one arbitrary key
+	var table encryption.MangleKeyTable
+	generator := encryption.BorlandRandKeyGenerator(seed)
+	generator.FillKeyBlock(table[2][:])
+	generator = encryption.BorlandRandKeyGenerator(seed + 12345)
+	generator.FillKeyBlock(table[5][:])
+	if _, err := io.ReadFull(rand.Reader, table[6][:]); err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []encryption.EncryptedBlock
+	for _, index := range []uint32{2, 5, 5, 6} {
+		b := encryption.NewEncryptedBlock(64)
+		if _, err := io.ReadFull(rand.Reader, b.DataBlock()); err != nil {
+			t.Fatal(err)
+		}
+
+		material := encryption.NewFlashKeyMaterial(encryption.NewMangleIndexGeneratorWrapper(&encryption.SecureRandomKeyGenerator{}, encryption.MangleIndexAlternateKey0+index))
+		material.AlternateKeyTable = &table
+		if err := b.Encrypt(material); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Block using a normal key is ignored
+	b := encryption.NewEncryptedBlock(64)
+	if err := b.Encrypt(encryption.NewFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{})); err != nil {
+		t.Fatal(err)
+	}
+	blocks = append(blocks, b)
+
+	recovered, found, err := SearchAlternateKeyTable(context.Background(), blocks, encryption.NewFlashKeyMaterial(nil), space, 0, true)
+	if !errors.Is(err, ErrNotInSpace) {
+		t.Fatalf("expected %s, got %v", ErrNotInSpace, err)
+	}
+
+	if found != [8]bool{2: true, 5: true} {
+		t.Fatalf("unexpected found keys %v", found)
+	}
+	if recovered[2] != table[2] || recovered[5] != table[5] {
+		t.Fatal("recovered table does not match")
+	}
+	if recovered[6] != encryption.AlternateMangleTable[6] {
+		t.Fatal("key not in space was modified")
+	}
+
+	material := encryption.NewFlashKeyMaterial(nil)
+	material.AlternateKeyTable = &recovered
+	for _, b := range blocks[:3] {
+		if err = b.Decrypt(material, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
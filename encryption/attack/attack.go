@@ -0,0 +1,103 @@
+// Package attack Known-plaintext key search for the Mangle cipher.
+//
+// Recovering an arbitrary 128-bit key from known plaintext is not feasible, see doc/ENCRYPTION.md. Mangle round keys enter
+// each round only by modular addition, with the four key words reused every four rounds. Each reuse carries a round constant
+// 4 higher, so shifting by four rounds equals adding 4 to each key word: a related-key slide, which needs blocks encrypted
+// under both keys, while captured blocks share one unknown key. Any four consecutive rounds use all key words, so no
+// meet-in-the-middle split exists, and differences between pairs cancel the key out of the first round only.
+//
+// Instead, keys are searched over structured spaces: keys derived from 32-bit generator seeds, partially known keys,
+// or key tables. Keys outside the searched space are reported as ErrNotInSpace.
+// One known block rules out a wrong candidate with probability 1 - 2^-64.
+package attack
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/utils"
+	"sync/atomic"
+)
+
+// ErrNotInSpace No candidate of the searched space matched
+var ErrNotInSpace = errors.New("key not in searched space")
+
+// KnownPair Plaintext and ciphertext of a single Mangle block
+type KnownPair struct {
+	Plaintext  uint64
+	Ciphertext uint64
+}
+
+// PairsFromBytes Splits plaintext and its ciphertext into KnownPair
+func PairsFromBytes(plaintext, ciphertext []byte) (pairs []KnownPair) {
+	if len(plaintext) != len(ciphertext) || len(plaintext)%encryption.MangleKeyBlockSize != 0 {
+		panic("len must match and be % 8")
+	}
+	for i := 0; i < len(plaintext); i += encryption.MangleKeyBlockSize {
+		pairs = append(pairs, KnownPair{
+			Plaintext:  binary.LittleEndian.Uint64(plaintext[i:]),
+			Ciphertext: binary.LittleEndian.Uint64(ciphertext[i:]),
+		})
+	}
+	return pairs
+}
+
+// Verify Whether key encrypts all pairs
+func Verify(key encryption.MangleKeyData, pairs []KnownPair) bool {
+	for _, p := range pairs {
+		if key.EncryptBlock(p.Plaintext) != p.Ciphertext {
+			return false
+		}
+	}
+	return true
+}
+
+// searchChunkSize Number of candidates claimed by a worker at a time
+const searchChunkSize = 1 << 12
+
+// Search Runs predicate over all indices of space across workers, returning the first index it accepts.
+// If workers is zero or less, runtime.NumCPU() is used. progress, if set, is called with the number of candidates tested so far,
+// see utils.ParallelRange
+func Search(ctx context.Context, size uint64, workers int, progress func(done, total uint64), predicate func(index uint64) bool) (uint64, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var found atomic.Bool
+	var result uint64
+
+	_ = utils.ParallelRange(ctx, size, searchChunkSize, workers, progress, func(start, end uint64) {
+		for index := start; index < end && ctx.Err() == nil; index++ {
+			if predicate(index) {
+				if found.CompareAndSwap(false, true) {
+					result = index
+				}
+				cancel()
+				return
+			}
+		}
+	})
+
+	if found.Load() {
+		return result, nil
+	} else if err := parent.Err(); err != nil {
+		return 0, err
+	}
+	return 0, ErrNotInSpace
+}
+
+// SearchKey Finds the key in space that encrypts all pairs, or returns ErrNotInSpace
+func SearchKey(ctx context.Context, pairs []KnownPair, space KeySpace, workers int) (encryption.MangleKeyData, error) {
+	if len(pairs) == 0 {
+		return encryption.MangleKeyData{}, errors.New("no known pairs")
+	}
+
+	index, err := Search(ctx, space.Size(), workers, nil, func(index uint64) bool {
+		return Verify(space.Key(index), pairs)
+	})
+	if err != nil {
+		return encryption.MangleKeyData{}, err
+	}
+	return space.Key(index), nil
+}
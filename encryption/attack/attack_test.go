@@ -0,0 +1,143 @@
+package attack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func randomUint32(t *testing.T) uint32 {
+	var buf [4]byte
+	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+		t.Fatal(err)
+	}
+	return binary.LittleEndian.Uint32(buf[:])
+}
+
+func knownPairs(t *testing.T, key encryption.MangleKeyData, n int) []KnownPair {
+	plaintext := make([]byte, encryption.MangleKeyBlockSize*n)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := slices.Clone(plaintext)
+	key.Encrypt(ciphertext)
+	return PairsFromBytes(plaintext, ciphertext)
+}
+
+func TestSearchKey_BorlandRand(t *testing.T) {
+	t.Parallel()
+
+	seed := randomUint32(t)
+	generator := encryption.BorlandRandKeyGenerator(seed)
+	var key encryption.MangleKeyData
+	generator.FillKeyBlock(key[:])
+
+	// Search a window around the seed
+	space := BorlandRandKeySpace{Start: seed - (seed & 0xffff), Count: 1 << 17}
+
+	recovered, err := SearchKey(context.Background(), knownPairs(t, key, 2), space, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != key {
+		t.Fatalf("expected key %x, got %x", key, recovered)
+	}
+}
+
+func TestSearchKey_Masked(t *testing.T) {
+	t.Parallel()
+
+	var key encryption.MangleKeyData
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 unknown bits spread over all key words
+	var mask encryption.MangleKeyData
+	for _, i := range []int{0, 5, 10, 15} {
+		mask[i] = 0x1f
+	}
+	space := MaskedKeySpace{Known: key, Mask: mask}
+	for i := range space.Known {
+		space.Known[i] ^= byte(randomUint32(t)) & mask[i]
+	}
+
+	recovered, err := SearchKey(context.Background(), knownPairs(t, key, 1), space, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != key {
+		t.Fatalf("expected key %x, got %x", key, recovered)
+	}
+}
+
+func TestSearchKey_Table(t *testing.T) {
+	t.Parallel()
+
+	key := encryption.HardcodedMangleTable[randomUint32(t)%8]
+	recovered, err := SearchKey(context.Background(), knownPairs(t, key, 1), ListKeySpace(encryption.HardcodedMangleTable[:]), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != key {
+		t.Fatalf("expected key %x, got %x", key, recovered)
+	}
+
+	var randomKey encryption.MangleKeyData
+	if _, err = io.ReadFull(rand.Reader, randomKey[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SearchKey(context.Background(), knownPairs(t, randomKey, 1), ListKeySpace(encryption.HardcodedMangleTable[:]), 1); !errors.Is(err, ErrNotInSpace) {
+		t.Fatalf("expected %s, got %v", ErrNotInSpace, err)
+	}
+}
+
+func TestSearchKey_Cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	var key encryption.MangleKeyData
+	if _, err := SearchKey(ctx, knownPairs(t, key, 1), FullBorlandRandKeySpace, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %s, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestMangleRelatedKeySlide(t *testing.T) {
+	t.Parallel()
+
+	var key, related encryption.MangleKeyData
+	for i := 0; i < len(key); i += 4 {
+		word := randomUint32(t)
+		binary.LittleEndian.PutUint32(key[i:], word)
+		binary.LittleEndian.PutUint32(related[i:], word+4)
+	}
+
+	// Rounds 4..47 under key equal rounds 0..43 under the related key
+	a, b := randomUint32(t), randomUint32(t)
+	slidA, slidB := a, b
+	for round := 4; round < encryption.MangleKeyRounds; round++ {
+		k := key.RoundKey(round) + uint32(round)
+		a, b = b, k+((b>>8)^(b<<6))+b+a
+	}
+	for round := 0; round < encryption.MangleKeyRounds-4; round++ {
+		k := related.RoundKey(round) + uint32(round)
+		slidA, slidB = slidB, k+((slidB>>8)^(slidB<<6))+slidB+slidA
+	}
+	if a != slidA || b != slidB {
+		t.Fatal("related key does not slide by four rounds")
+	}
+
+	// Without the related key, the full cipher has no such period
+	block := uint64(randomUint32(t)) | uint64(randomUint32(t))<<32
+	if key.EncryptBlock(block) == related.EncryptBlock(block) {
+		t.Fatal("related keys encrypt equally")
+	}
+}
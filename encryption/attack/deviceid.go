@@ -0,0 +1,263 @@
+package attack
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// DeviceIdSpace Indexable set of candidate device ids
+type DeviceIdSpace interface {
+	Size() uint64
+	DeviceId(index uint64) encryption.DeviceId
+}
+
+// ListDeviceIdSpace Fixed list of candidate device ids
+type ListDeviceIdSpace []encryption.DeviceId
+
+func (s ListDeviceIdSpace) Size() uint64 {
+	return uint64(len(s))
+}
+
+func (s ListDeviceIdSpace) DeviceId(index uint64) encryption.DeviceId {
+	return s[index]
+}
+
+// MaskedDeviceIdSpace Partially known device id. Bits set in Mask are unknown and enumerated, up to 64 of them
+type MaskedDeviceIdSpace struct {
+	Known encryption.DeviceId
+	Mask  encryption.DeviceId
+}
+
+func (s MaskedDeviceIdSpace) Size() uint64 {
+	n := bits.OnesCount32(s.Mask[0]) + bits.OnesCount32(s.Mask[1]) + bits.OnesCount32(s.Mask[2])
+	if n >= 64 {
+		panic("too many unknown bits")
+	}
+	return 1 << n
+}
+
+func (s MaskedDeviceIdSpace) DeviceId(index uint64) (deviceId encryption.DeviceId) {
+	for i := range deviceId {
+		deviceId[i] = s.Known[i] &^ s.Mask[i]
+		for mask := s.Mask[i]; mask != 0; mask &= mask - 1 {
+			deviceId[i] |= uint32(index&1) << bits.TrailingZeros32(mask)
+			index >>= 1
+		}
+	}
+	return deviceId
+}
+
+// DeviceIdKeySpace Keys of device locked code, see encryption.DeviceMangleKeyOffset0
+type DeviceIdKeySpace struct {
+	DeviceIdSpace
+	// Profile Optional, encryption.DefaultKeyProfile is used if unset
+	Profile *encryption.KeyProfile
+}
+
+func (s DeviceIdKeySpace) Key(index uint64) encryption.MangleKeyData {
+	return *keyProfile(s.Profile).DeviceMangleKeyOffset0(s.DeviceId(index))
+}
+
+// keyProfile Returns profile, or encryption.DefaultKeyProfile if nil
+func keyProfile(profile *encryption.KeyProfile) *encryption.KeyProfile {
+	if profile == nil {
+		return encryption.DefaultKeyProfile()
+	}
+	return profile
+}
+
+// DeviceIdWordSpace Candidate values of each device id word, enumerated independently.
+// Index enumerates word 2 fastest, then word 1, then word 0
+type DeviceIdWordSpace [3][]uint32
+
+func (s DeviceIdWordSpace) Size() uint64 {
+	return uint64(len(s[0])) * uint64(len(s[1])) * uint64(len(s[2]))
+}
+
+func (s DeviceIdWordSpace) DeviceId(index uint64) (deviceId encryption.DeviceId) {
+	for i := len(s) - 1; i >= 0; i-- {
+		n := uint64(len(s[i]))
+		deviceId[i] = s[i][index%n]
+		index /= n
+	}
+	return deviceId
+}
+
+// Words Candidate values of each word of s
+func (s MaskedDeviceIdSpace) Words() (words DeviceIdWordSpace) {
+	// Size panics on too many unknown bits
+	_ = s.Size()
+	for i := range words {
+		words[i] = make([]uint32, 0, 1<<bits.OnesCount32(s.Mask[i]))
+		for index := uint64(0); index < uint64(cap(words[i])); index++ {
+			word := s.Known[i] &^ s.Mask[i]
+			n := index
+			for mask := s.Mask[i]; mask != 0; mask &= mask - 1 {
+				word |= uint32(n&1) << bits.TrailingZeros32(mask)
+				n >>= 1
+			}
+			words[i] = append(words[i], word)
+		}
+	}
+	return words
+}
+
+// STM32UIDWordSpace Candidate values of each device id word of s, for RecoverDeviceId.
+// Word 0 holds the coordinates, word 1 the wafer and first three lot characters, and word 2 the last four lot characters.
+// Lists are materialized, so unknown lot characters are limited in practice to the last four or a small LotCharset
+func STM32UIDWordSpace(s encryption.STM32UIDSpace) (words DeviceIdWordSpace, err error) {
+	if err = s.Validate(); err != nil {
+		return words, err
+	}
+
+	charset := s.LotCharset
+	if charset == "" {
+		charset = encryption.STM32UIDLotCharset
+	}
+	lotChars := func(i int) string {
+		if i < len(s.Lot) {
+			return s.Lot[i : i+1]
+		}
+		return charset
+	}
+	appendChars := func(words []uint32, chars string, shift int) []uint32 {
+		result := make([]uint32, 0, len(words)*len(chars))
+		for _, c := range []byte(chars) {
+			for _, word := range words {
+				result = append(result, word|uint32(c)<<shift)
+			}
+		}
+		return result
+	}
+
+	for y := uint32(s.YMin); y <= uint32(s.YMax); y++ {
+		for x := uint32(s.XMin); x <= uint32(s.XMax); x++ {
+			words[0] = append(words[0], x|y<<16)
+		}
+	}
+
+	for wafer := uint32(s.WaferMin); wafer <= uint32(s.WaferMax); wafer++ {
+		words[1] = append(words[1], wafer)
+	}
+	for i := 0; i < 3; i++ {
+		words[1] = appendChars(words[1], lotChars(i), 8+i*8)
+	}
+
+	words[2] = []uint32{0}
+	for i := 3; i < encryption.STM32UIDLotSize; i++ {
+		words[2] = appendChars(words[2], lotChars(i), (i-3)*8)
+	}
+	return words, nil
+}
+
+// mangleRound Applies round with scheduled key k to dataA, dataB, as in encryption.MangleKeySchedule
+func mangleRound(dataA, dataB, k uint32) (uint32, uint32) {
+	return dataB, k + ((dataB >> 8) ^ (dataB << 6)) + dataB + dataA
+}
+
+// mangleRoundInverse Inverse of mangleRound
+func mangleRoundInverse(dataA, dataB, k uint32) (uint32, uint32) {
+	return dataB - dataA - ((dataA >> 8) ^ (dataA << 6)) - k, dataA
+}
+
+// RecoverDeviceId Finds the device id in space whose device locked code key encrypts all pairs.
+// Known plaintext can be a terminator, a Thumb prologue or erased 0xFF / zero padding at the end of code.
+//
+// The key is the device id XOR a constant outer key, with the fourth word duplicating ^deviceId[0], so each key word
+// depends on a single device id word and only 96 of its 128 bits are unknown. Rounds 0 and 1 use key words 0 and 1,
+// and round 47 uses key word 3, which are all fixed by device id words 0 and 1. These three rounds are computed once
+// per pair of words 0 and 1, and only the 45 middle rounds are run for each candidate of word 2.
+// The full space is still 2^96, so words must be constrained, for example via MaskedDeviceIdSpace.Words.
+// Once a key word pair is found, the id is read back with encryption.KeyProfile.DeviceIdFromMangleKeyOffset0.
+// profile may be nil for encryption.DefaultKeyProfile. If workers is zero or less, runtime.NumCPU() is used
+func RecoverDeviceId(ctx context.Context, pairs []KnownPair, space DeviceIdWordSpace, profile *encryption.KeyProfile, workers int) (encryption.DeviceId, error) {
+	if len(pairs) == 0 {
+		return encryption.DeviceId{}, errors.New("no known pairs")
+	}
+
+	profile = keyProfile(profile)
+	first := pairs[0]
+
+	var result atomic.Pointer[encryption.MangleKeyData]
+	_, err := Search(ctx, uint64(len(space[0]))*uint64(len(space[1])), workers, nil, func(index uint64) bool {
+		// Key with word 2 zero, so key word 2 is the outer key word
+		key := *profile.DeviceMangleKeyOffset0(encryption.DeviceId{
+			space[0][index/uint64(len(space[1]))],
+			space[1][index%uint64(len(space[1]))],
+			0,
+		})
+		schedule := key.Schedule()
+		outer2 := key.RoundKey(2)
+
+		a, b := uint32(first.Plaintext), uint32(first.Plaintext>>32)
+		a, b = mangleRound(a, b, schedule[0])
+		a, b = mangleRound(a, b, schedule[1])
+		lastA, lastB := mangleRoundInverse(uint32(first.Ciphertext), uint32(first.Ciphertext>>32), schedule[encryption.MangleKeyRounds-1])
+
+		for _, word := range space[2] {
+			k2 := word ^ outer2
+			for round := 2; round < encryption.MangleKeyRounds; round += 4 {
+				schedule[round] = k2 + uint32(round)
+			}
+
+			dataA, dataB := a, b
+			for _, k := range schedule[2 : encryption.MangleKeyRounds-1] {
+				dataA, dataB = mangleRound(dataA, dataB, k)
+			}
+			if dataA != lastA || dataB != lastB {
+				continue
+			}
+
+			binary.LittleEndian.PutUint32(key[8:], k2)
+			if Verify(key, pairs[1:]) {
+				result.Store(&key)
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return encryption.DeviceId{}, err
+	}
+
+	deviceId, ok := profile.DeviceIdFromMangleKeyOffset0(*result.Load())
+	if !ok {
+		return encryption.DeviceId{}, errors.New("recovered key does not derive from a device id")
+	}
+	return deviceId, nil
+}
+
+// BruteforceDeviceCode Decrypts code with each device id in space, for example an encryption.STM32UIDSpace, until predicate accepts the plaintext.
+// profile may be nil for encryption.DefaultKeyProfile. If workers is zero or less, runtime.NumCPU() is used
+func BruteforceDeviceCode(ctx context.Context, code []byte, space DeviceIdSpace, profile *encryption.KeyProfile, workers int, predicate func(plaintext []byte) bool) (encryption.DeviceId, error) {
+	if len(code)%encryption.MangleKeyBlockSize != 0 {
+		panic("len must be % 8")
+	}
+	if v, ok := space.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return encryption.DeviceId{}, err
+		}
+	}
+
+	profile = keyProfile(profile)
+
+	var buffers sync.Pool
+	index, err := Search(ctx, space.Size(), workers, nil, func(index uint64) bool {
+		buf, _ := buffers.Get().(*[]byte)
+		if buf == nil {
+			buf = new([]byte)
+		}
+		defer buffers.Put(buf)
+		*buf = append((*buf)[:0], code...)
+		return predicate(profile.DecryptDeviceCode(space.DeviceId(index), *buf))
+	})
+	if err != nil {
+		return encryption.DeviceId{}, err
+	}
+	return space.DeviceId(index), nil
+}
@@ -0,0 +1,167 @@
+package attack
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"slices"
+	"testing"
+)
+
+func TestRecoverDeviceId(t *testing.T) {
+	t.Parallel()
+
+	deviceId := encryption.DeviceId{randomUint32(t), randomUint32(t), randomUint32(t)}
+
+	// Zero padding at the end of device locked code
+	ciphertext := encryption.EncryptDeviceCode(deviceId, make([]byte, encryption.MangleKeyBlockSize*2))
+	pairs := PairsFromBytes(make([]byte, encryption.MangleKeyBlockSize*2), ciphertext)
+
+	// Random candidates for each word, including the actual word at a random position
+	var space DeviceIdWordSpace
+	for i, n := range []int{32, 16, 512} {
+		for j := 0; j < n; j++ {
+			space[i] = append(space[i], randomUint32(t))
+		}
+		space[i][randomUint32(t)%uint32(n)] = deviceId[i]
+	}
+
+	recovered, err := RecoverDeviceId(context.Background(), pairs, space, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != deviceId {
+		t.Fatalf("expected %08x, got %08x", deviceId, recovered)
+	}
+
+	// Same space without the actual word 2
+	space[2] = slices.DeleteFunc(space[2], func(word uint32) bool {
+		return word == deviceId[2]
+	})
+	if _, err = RecoverDeviceId(context.Background(), pairs, space, nil, 0); !errors.Is(err, ErrNotInSpace) {
+		t.Fatalf("expected ErrNotInSpace, got %v", err)
+	}
+}
+
+func TestRecoverDeviceId_Profile(t *testing.T) {
+	t.Parallel()
+
+	profile := *encryption.DefaultKeyProfile()
+	profile.OuterKeyOffsetDeviceCode = 3
+
+	deviceId := encryption.DeviceId{randomUint32(t), randomUint32(t), randomUint32(t)}
+	ciphertext := profile.EncryptDeviceCode(deviceId, make([]byte, encryption.MangleKeyBlockSize*2))
+	pairs := PairsFromBytes(make([]byte, encryption.MangleKeyBlockSize*2), ciphertext)
+
+	space := DeviceIdWordSpace{{randomUint32(t), deviceId[0]}, {deviceId[1]}, {randomUint32(t), deviceId[2], randomUint32(t)}}
+
+	recovered, err := RecoverDeviceId(context.Background(), pairs, space, &profile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != deviceId {
+		t.Fatalf("expected %08x, got %08x", deviceId, recovered)
+	}
+
+	if _, err = RecoverDeviceId(context.Background(), pairs, space, nil, 0); !errors.Is(err, ErrNotInSpace) {
+		t.Fatalf("expected ErrNotInSpace with default profile, got %v", err)
+	}
+}
+
+func TestMaskedDeviceIdSpace_Words(t *testing.T) {
+	t.Parallel()
+
+	space := MaskedDeviceIdSpace{
+		Known: encryption.DeviceId{randomUint32(t), randomUint32(t), randomUint32(t)},
+		Mask:  encryption.DeviceId{0x000f00ff, 0x0f000000, 0},
+	}
+	words := space.Words()
+	if words.Size() != space.Size() {
+		t.Fatalf("expected size %d, got %d", space.Size(), words.Size())
+	}
+
+	for _, index := range []uint64{0, 1, 0x1234, space.Size() - 1, uint64(randomUint32(t)) % space.Size()} {
+		deviceId := words.DeviceId(index)
+		if !slices.Contains(words[0], deviceId[0]) || !slices.Contains(words[1], deviceId[1]) || deviceId[2] != space.Known[2] {
+			t.Fatalf("invalid device id %08x", deviceId)
+		}
+		for i := range deviceId {
+			if deviceId[i]&^space.Mask[i] != space.Known[i]&^space.Mask[i] {
+				t.Fatalf("known bits changed in %08x", deviceId)
+			}
+		}
+	}
+}
+
+func TestBruteforceDeviceCode(t *testing.T) {
+	t.Parallel()
+
+	space := encryption.STM32UIDSpace{
+		Lot:      "PKMU4",
+		WaferMin: 1,
+		WaferMax: 3,
+		XMin:     80,
+		XMax:     90,
+		YMin:     55,
+		YMax:     60,
+	}
+
+	deviceId := space.DeviceId(uint64(randomUint32(t)) % space.Size())
+
+	// Code ending in zero padding
+	code := make([]byte, encryption.MangleKeyBlockSize*4)
+	copy(code, "\x00\xb5\x80\xb0\x00\xaf\x00\x20\x80\xbd")
+	encryption.EncryptDeviceCode(deviceId, code)
+
+	recovered, err := BruteforceDeviceCode(context.Background(), code, space, nil, 0, func(plaintext []byte) bool {
+		return binary.LittleEndian.Uint64(plaintext[len(plaintext)-encryption.MangleKeyBlockSize:]) == 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != deviceId {
+		t.Fatalf("expected %s, got %s", encryption.ParseSTM32UID(deviceId), encryption.ParseSTM32UID(recovered))
+	}
+
+	space.XMin, space.XMax = space.XMax, space.XMin
+	if _, err = BruteforceDeviceCode(context.Background(), code, space, nil, 0, func(plaintext []byte) bool {
+		return true
+	}); err == nil {
+		t.Fatal("expected error on invalid space")
+	}
+}
+
+func TestSTM32UIDWordSpace(t *testing.T) {
+	t.Parallel()
+
+	space := encryption.STM32UIDSpace{
+		Lot:        "PK",
+		LotCharset: "MU4 ",
+		WaferMin:   2,
+		WaferMax:   3,
+		XMin:       80,
+		XMax:       82,
+		YMin:       55,
+		YMax:       56,
+	}
+	words, err := STM32UIDWordSpace(space)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words.Size() != space.Size() {
+		t.Fatalf("expected size %d, got %d", space.Size(), words.Size())
+	}
+
+	for i := uint64(0); i < space.Size(); i++ {
+		deviceId := space.DeviceId(i)
+		if !slices.Contains(words[0], deviceId[0]) || !slices.Contains(words[1], deviceId[1]) || !slices.Contains(words[2], deviceId[2]) {
+			t.Fatalf("device id %s not in word space", space.UID(i))
+		}
+	}
+
+	space.Lot = "PKMU42 X"
+	if _, err = STM32UIDWordSpace(space); err == nil {
+		t.Fatal("expected error on long lot")
+	}
+}
@@ -0,0 +1,95 @@
+package attack
+
+import (
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"math/bits"
+)
+
+// KeySpace Indexable set of candidate keys, so it can be partitioned across workers
+type KeySpace interface {
+	Size() uint64
+	Key(index uint64) encryption.MangleKeyData
+}
+
+// ListKeySpace Fixed list of candidate keys, for example a encryption.MangleKeyTable
+type ListKeySpace []encryption.MangleKeyData
+
+func (s ListKeySpace) Size() uint64 {
+	return uint64(len(s))
+}
+
+func (s ListKeySpace) Key(index uint64) encryption.MangleKeyData {
+	return s[index]
+}
+
+// BorlandRandKeySpace Keys generated by encryption.BorlandRandKeyGenerator for seeds in [Start, Start+Count)
+type BorlandRandKeySpace struct {
+	Start uint32
+	Count uint64
+}
+
+// FullBorlandRandKeySpace Covers all 2^32 seeds
+var FullBorlandRandKeySpace = BorlandRandKeySpace{Start: 0, Count: 1 << 32}
+
+func (s BorlandRandKeySpace) Size() uint64 {
+	return s.Count
+}
+
+func (s BorlandRandKeySpace) Key(index uint64) (key encryption.MangleKeyData) {
+	generator := encryption.BorlandRandKeyGenerator(s.Start + uint32(index))
+	generator.FillKeyBlock(key[:])
+	return key
+}
+
+// BorlandRandByteKeySpace Keys generated by encryption.BorlandRandByteKeyGenerator for seeds in [Start, Start+Count)
+type BorlandRandByteKeySpace struct {
+	Start uint32
+	Count uint64
+}
+
+// FullBorlandRandByteKeySpace Covers all 2^32 seeds
+var FullBorlandRandByteKeySpace = BorlandRandByteKeySpace{Start: 0, Count: 1 << 32}
+
+func (s BorlandRandByteKeySpace) Size() uint64 {
+	return s.Count
+}
+
+func (s BorlandRandByteKeySpace) Key(index uint64) (key encryption.MangleKeyData) {
+	generator := encryption.BorlandRandByteKeyGenerator(s.Start + uint32(index))
+	generator.FillKeyBlock(key[:])
+	return key
+}
+
+// MaskedKeySpace Partially known key. Bits set in Mask are unknown and enumerated, up to 64 of them
+type MaskedKeySpace struct {
+	Known encryption.MangleKeyData
+	Mask  encryption.MangleKeyData
+}
+
+func (s MaskedKeySpace) unknownBits() (n int) {
+	for _, b := range s.Mask {
+		n += bits.OnesCount8(b)
+	}
+	return n
+}
+
+func (s MaskedKeySpace) Size() uint64 {
+	n := s.unknownBits()
+	if n >= 64 {
+		panic("too many unknown bits")
+	}
+	return 1 << n
+}
+
+func (s MaskedKeySpace) Key(index uint64) (key encryption.MangleKeyData) {
+	for i := range key {
+		key[i] = s.Known[i] &^ s.Mask[i]
+		for bit := 0; bit < 8; bit++ {
+			if s.Mask[i]&(1<<bit) > 0 {
+				key[i] |= byte(index&1) << bit
+				index >>= 1
+			}
+		}
+	}
+	return key
+}
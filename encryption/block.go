@@ -4,7 +4,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
 )
 
 const EncryptedBlockKeySize = 512
@@ -51,16 +50,9 @@ func (b EncryptedBlock) Reset() {
 	clear(b)
 }
 
-func (b EncryptedBlock) generateKeyBlock(material KeyMaterial) (mangleIndex uint32) {
+func (b EncryptedBlock) generateKeyBlock(material KeyMaterial, crcValue uint32) (mangleIndex uint32) {
 	_ = b[EncryptedBlockKeySize-1]
 
-	var crcValue uint32
-	if material.CRC != nil {
-		crcValue = material.CRC(b.DataBlock())
-	} else {
-		crcValue = crc.CalculateCRC(b.DataBlock())
-	}
-
 	binary.LittleEndian.PutUint32(b[EncryptedBlockCRC1Offset:], crcValue)
 	binary.LittleEndian.PutUint32(b[EncryptedBlockCRC2Offset:], crcValue)
 
@@ -92,76 +84,80 @@ const (
 	MangleIndexDeviceKey = 0xffff
 )
 
-func (b EncryptedBlock) Encrypt(material KeyMaterial) error {
-
-	mangleIndex := b.generateKeyBlock(material)
-
-	// Mangle of data
-	b.MangleKey().Encrypt(b.DataBlock())
-
-	var keyData MangleKeyData
-
-	// Inner mangle of key
+// mangleKeyData Inner key selected by mangleIndex
+func mangleKeyData(material KeyMaterial, mangleIndex uint32) (keyData MangleKeyData, err error) {
 	if mangleIndex <= MangleIndexNormalKey7 {
-		keyData = HardcodedMangleTable[mangleIndex]
+		keyData = material.keyTable()[mangleIndex]
 	} else if mangleIndex <= MangleIndexAlternateKey7 {
-		if material.AlternateKeyTable == nil {
-			keyData = AlternateMangleTable[mangleIndex-MangleIndexAlternateKey0]
-		} else {
-			keyData = material.AlternateKeyTable[mangleIndex-MangleIndexAlternateKey0]
-		}
+		keyData = material.alternateKeyTable()[mangleIndex-MangleIndexAlternateKey0]
 	} else {
 		if mangleIndex != MangleIndexDeviceKey {
-			return errors.New("invalid key number")
+			return keyData, errors.New("invalid key number")
 		}
 
 		if material.DeviceKey == nil {
-			return errors.New("unsupported device key")
+			return keyData, errors.New("unsupported device key")
 		}
 
 		keyData = *material.DeviceKey
 	}
+	return keyData, nil
+}
+
+func (b EncryptedBlock) Encrypt(material KeyMaterial) error {
+	return b.EncryptWithCRC(material, material.CalculateCRC(b.DataBlock()))
+}
+
+// EncryptWithCRC As Encrypt, storing crcValue in the key block instead of the CRC of block data.
+// Used for compressed blocks, whose CRC is calculated over uncompressed data
+func (b EncryptedBlock) EncryptWithCRC(material KeyMaterial, crcValue uint32) error {
+	mangleIndex := b.generateKeyBlock(material, crcValue)
+
+	// Mangle of data
+	b.MangleKey().Encrypt(b.DataBlock())
+
+	return b.encryptKeyBlock(material, mangleIndex)
+}
+
+// encryptKeyBlock Inner and outer mangle of a generated key block
+func (b EncryptedBlock) encryptKeyBlock(material KeyMaterial, mangleIndex uint32) error {
+	keyData, err := mangleKeyData(material, mangleIndex)
+	if err != nil {
+		return err
+	}
 
+	// Inner mangle of key
 	keyData.Encrypt(b.MangleKeyBlock())
 
 	binary.LittleEndian.PutUint16(b[EncryptedBlockMangleIndexOffset:], uint16(mangleIndex))
 
 	// Outer mangle of key
-	HardcodedMangleTable[material.OuterKeyOffset].Encrypt(b.KeyBlock())
+	material.OuterKey().Encrypt(b.KeyBlock())
 
 	return nil
 }
 
-func (b EncryptedBlock) Decrypt(material KeyMaterial, verifyCrc bool) (err error) {
+// decryptKeyBlock Outer and inner unmangle of key block
+func (b EncryptedBlock) decryptKeyBlock(material KeyMaterial) error {
 	// Outer unmangle of key
-	HardcodedMangleTable[material.OuterKeyOffset].Decrypt(b.KeyBlock())
-
-	mangleIndex := b.MangleIndex()
-
-	var keyData MangleKeyData
-	if mangleIndex <= MangleIndexNormalKey7 {
-		keyData = HardcodedMangleTable[mangleIndex]
-	} else if mangleIndex <= MangleIndexAlternateKey7 {
-		if material.AlternateKeyTable == nil {
-			keyData = AlternateMangleTable[mangleIndex-MangleIndexAlternateKey0]
-		} else {
-			keyData = material.AlternateKeyTable[mangleIndex-MangleIndexAlternateKey0]
-		}
-	} else {
-		if mangleIndex != MangleIndexDeviceKey {
-			return errors.New("invalid key number")
-		}
+	material.OuterKey().Decrypt(b.KeyBlock())
 
-		if material.DeviceKey == nil {
-			return errors.New("unsupported device key")
-		}
-
-		keyData = *material.DeviceKey
+	keyData, err := mangleKeyData(material, b.MangleIndex())
+	if err != nil {
+		return err
 	}
 
 	// Inner unmangle of key + CRC data
 	keyData.Decrypt(b.MangleKeyBlock())
 
+	return nil
+}
+
+func (b EncryptedBlock) Decrypt(material KeyMaterial, verifyCrc bool) (err error) {
+	if err = b.decryptKeyBlock(material); err != nil {
+		return err
+	}
+
 	// Unmangle of data
 	b.MangleKey().Decrypt(b.DataBlock())
 
@@ -172,14 +168,7 @@ func (b EncryptedBlock) Decrypt(material KeyMaterial, verifyCrc bool) (err error
 	}
 
 	if verifyCrc {
-		var calculatedCrc uint32
-		if material.CRC != nil {
-			calculatedCrc = material.CRC(b.DataBlock())
-		} else {
-			calculatedCrc = crc.CalculateCRC(b.DataBlock())
-		}
-
-		if calculatedCrc != crc1 {
+		if calculatedCrc := material.CalculateCRC(b.DataBlock()); calculatedCrc != crc1 {
 			return fmt.Errorf("data CRC not matching: expected %08x, got %08x", crc1, calculatedCrc)
 		}
 	}
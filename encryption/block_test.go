@@ -339,3 +339,59 @@ func BenchmarkEncryptedBlock_Decrypt(b *testing.B) {
 		}
 	})
 }
+
+func benchmarkMangle(b *testing.B, fn func(key MangleKeyData, data []byte)) {
+	const dataSize = 1024 * 1024
+
+	var key MangleKeyData
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, dataSize)
+
+	b.SetBytes(dataSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(key, data)
+	}
+}
+
+func BenchmarkMangleKeyData_Encrypt(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		key.Encrypt(data)
+	})
+}
+
+func BenchmarkMangleKeySchedule_Encrypt(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		schedule := key.Schedule()
+		schedule.Encrypt(data)
+	})
+}
+
+func BenchmarkMangleKeySchedule_EncryptParallel(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		schedule := key.Schedule()
+		schedule.EncryptParallel(data, 0)
+	})
+}
+
+func BenchmarkMangleKeyData_Decrypt(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		key.Decrypt(data)
+	})
+}
+
+func BenchmarkMangleKeySchedule_Decrypt(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		schedule := key.Schedule()
+		schedule.Decrypt(data)
+	})
+}
+
+func BenchmarkMangleKeySchedule_DecryptParallel(b *testing.B) {
+	benchmarkMangle(b, func(key MangleKeyData, data []byte) {
+		schedule := key.Schedule()
+		schedule.DecryptParallel(data, 0)
+	})
+}
@@ -2,6 +2,7 @@ package encryption
 
 import (
 	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/math"
 )
 
 // BorlandRandMultiplier 22695477
@@ -17,6 +18,15 @@ const BorlandRandModulus = 0xFFFFFFFF
 // BorlandRandMultiplierInverse Calculated using math.ModularMultiplicativeInverseFixed(uint32(BorlandRandMultiplier))
 const BorlandRandMultiplierInverse uint32 = 0x2925141D
 
+// BorlandRandLCG Generic form, to jump ahead or compute distance between seeds
+var BorlandRandLCG = math.LCG{
+	Multiplier:  uint64(BorlandRandMultiplier),
+	Addend:      uint64(BorlandRandAddend),
+	ModulusBits: 32,
+	OutputShift: BorlandRandOutputShift,
+	OutputMask:  uint64(BorlandRandOutputMask),
+}
+
 // BorlandRand Borland C++ rand()
 func BorlandRand(seed uint32) (newSeed uint32, output uint16) {
 	newSeed = BorlandRandNextSeed(seed)
@@ -0,0 +1,30 @@
+package encryption
+
+import "testing"
+
+func TestBorlandRandLCG(t *testing.T) {
+	t.Parallel()
+
+	const seed = 0x12345678
+	generator := BorlandRandKeyGenerator(seed)
+
+	b := NewEncryptedBlock(64)
+	if err := b.Encrypt(NewFlashKeyMaterial(&generator)); err != nil {
+		t.Fatal(err)
+	}
+
+	distance, err := BorlandRandLCG.Distance(seed, uint64(generator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distance != BorlandRandCallsPerBlock {
+		t.Fatalf("expected %d calls, got %d", BorlandRandCallsPerBlock, distance)
+	}
+
+	if uint32(BorlandRandLCG.Previous(uint64(generator))) != BorlandRandPreviousSeed(uint32(generator)) {
+		t.Fatal("previous seed mismatch")
+	}
+	if uint16(BorlandRandLCG.Output(uint64(generator))) != BorlandRandOutput(uint32(generator)) {
+		t.Fatal("output mismatch")
+	}
+}
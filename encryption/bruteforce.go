@@ -1,12 +1,18 @@
 package encryption
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/utils"
 	"math/bits"
 	"slices"
+	"sync"
 )
 
+// bruteforceChunkSize Number of candidates claimed by a worker at a time
+const bruteforceChunkSize = 1 << 12
+
 // IsKeyBorlandSeedLikely The generator used on BorlandRand does not set the highest bit, as such it can be detected
 func IsKeyBorlandSeedLikely(b EncryptedBlock, material KeyMaterial) bool {
 	data := slices.Clone(b)
@@ -15,23 +21,16 @@ func IsKeyBorlandSeedLikely(b EncryptedBlock, material KeyMaterial) bool {
 		return false
 	}
 
-	for i := EncryptedBlockMangleKeyOffset; i < EncryptedBlockCRC1Offset; i += 2 {
-		if binary.LittleEndian.Uint16(data[i:])&0x8000 > 0 {
-			return false
-		}
-	}
-
-	for i := EncryptedBlockPaddingKeyOffset; i < EncryptedBlockKeySize; i += 2 {
-		if binary.LittleEndian.Uint16(data[i:])&0x8000 > 0 {
-			return false
-		}
-	}
-
-	return true
+	return isKeyBorlandLikely(data)
 }
 
 func BruteforceBorlandSeed(b EncryptedBlock, material KeyMaterial) ([]uint32, error) {
+	return BruteforceBorlandSeedContext(context.Background(), b, material, 1, nil)
+}
 
+// BruteforceBorlandSeedContext As BruteforceBorlandSeed, with candidates shared across workers.
+// If workers is zero or less, runtime.NumCPU() is used. progress, if set, is called with the number of candidates tested so far
+func BruteforceBorlandSeedContext(ctx context.Context, b EncryptedBlock, material KeyMaterial, workers int, progress func(done, total uint64)) ([]uint32, error) {
 	if !IsKeyBorlandSeedLikely(b, material) {
 		return nil, errors.New("not a borland rand seed")
 	}
@@ -42,66 +41,76 @@ func BruteforceBorlandSeed(b EncryptedBlock, material KeyMaterial) ([]uint32, er
 		return nil, err
 	}
 	// Efficient search as LCG leaks 15 bits each time, by backwards looping and solving across uint17 range
+	return bruteforceBorlandSeed(ctx, data, 2, BorlandRandOutputMask, workers, progress)
+}
+
+func BruteforceBorlandSeedBytes(b EncryptedBlock, material KeyMaterial) ([]uint32, error) {
+	return BruteforceBorlandSeedBytesContext(context.Background(), b, material, 1, nil)
+}
+
+// BruteforceBorlandSeedBytesContext As BruteforceBorlandSeedBytes, with candidates shared across workers.
+// If workers is zero or less, runtime.NumCPU() is used. progress, if set, is called with the number of candidates tested so far
+func BruteforceBorlandSeedBytesContext(ctx context.Context, b EncryptedBlock, material KeyMaterial, workers int, progress func(done, total uint64)) ([]uint32, error) {
+	data := slices.Clone(b)
+	err := data.Decrypt(material, false)
+	if err != nil {
+		return nil, err
+	}
+	// Efficient search as LCG leaks 8 bits each time, by backwards looping and solving across uint24 range
+	return bruteforceBorlandSeed(ctx, data, 1, BorlandRandOutputMask&0xFF, workers, progress)
+}
+
+// bruteforceBorlandSeed Solves seed from decrypted data key, where each rand() output was written as dataSize bytes, masked by outputMask
+func bruteforceBorlandSeed(ctx context.Context, data EncryptedBlock, dataSize int, outputMask uint32, workers int, progress func(done, total uint64)) ([]uint32, error) {
+	readOutput := func(index int) uint32 {
+		if dataSize == 2 {
+			return uint32(binary.LittleEndian.Uint16(data.KeyBlock()[index:]))
+		}
+		return uint32(data.KeyBlock()[index])
+	}
 
-	const dataSize = 2
 	const stateDataStart = EncryptedBlockMangleKeyOffset
-	const stateDataEnd = EncryptedBlockCRC1Offset - dataSize
-	validStateBits := bits.OnesCount32(BorlandRandOutputMask)
-	validStateInverseBits := bits.OnesCount32(^BorlandRandOutputMask)
-	var stateOutputMask = BorlandRandOutputMask << BorlandRandOutputShift
+	stateDataEnd := EncryptedBlockCRC1Offset - dataSize
+	validStateBits := bits.OnesCount32(outputMask)
+	validStateInverseBits := bits.OnesCount32(^outputMask)
+	var stateOutputMask = outputMask << BorlandRandOutputShift
 
-	statesBuf := make([]uint32, 0, 32-validStateBits)
-	getPossibleStates := func(state uint32, previousOutput uint16) (states []uint32) {
-		var seed, prevSeed uint32
+	previousOutput := readOutput(stateDataEnd - dataSize)
+	previousState := (readOutput(stateDataEnd) << BorlandRandOutputShift) & stateOutputMask
 
-		states = statesBuf[:0]
+	var possibleStates []uint32
+	var lock sync.Mutex
 
-		var limit uint32 = 1 << validStateInverseBits
-		for n := uint32(0); n < limit; n++ {
+	err := utils.ParallelRange(ctx, 1<<validStateInverseBits, bruteforceChunkSize, workers, progress, func(start, end uint64) {
+		var seed, prevSeed uint32
+		var states []uint32
+		for n := uint32(start); n < uint32(end); n++ {
 			// Fills the output part of the state
-			seed = (state & stateOutputMask) | ((n & (^uint32(0xFFFF))) << validStateBits) | (n & 0xFFFF)
+			seed = previousState | ((n & (^uint32(0xFFFF))) << validStateBits) | (n & 0xFFFF)
 
 			prevSeed = BorlandRandPreviousSeed(seed)
 
-			if BorlandRandOutput(prevSeed) == previousOutput {
+			if uint32(BorlandRandOutput(prevSeed))&outputMask == previousOutput {
 				states = append(states, prevSeed)
 			}
 		}
-		return states
-	}
-
-	var possibleStates []uint32
 
-	stateIndex := stateDataEnd
-	{
-		previousOutput := binary.LittleEndian.Uint16(data.KeyBlock()[stateIndex-dataSize:])
-		var nextStates []uint32
-		previousState := (uint32(binary.LittleEndian.Uint16(data.KeyBlock()[stateIndex:])) << BorlandRandOutputShift) & stateOutputMask
-		states := getPossibleStates(previousState, previousOutput)
-		if len(nextStates) == 0 {
-			nextStates = states
-			states = slices.Compact(states)
-			nextStates = slices.Clone(states)
-		} else {
-			for i := len(nextStates) - 1; i >= 0; i-- {
-				if !slices.Contains(states, nextStates[i]) {
-					nextStates = slices.Delete(nextStates, i, i+1)
-				}
-			}
-		}
-
-		slices.Sort(nextStates)
-		nextStates = slices.Compact(nextStates)
-		possibleStates = nextStates
-
-		stateIndex -= dataSize
+		lock.Lock()
+		defer lock.Unlock()
+		possibleStates = append(possibleStates, states...)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	slices.Sort(possibleStates)
+	possibleStates = slices.Compact(possibleStates)
+
 	//Last rounds backwards with checks
-	for ; stateIndex >= stateDataStart; stateIndex -= dataSize {
+	for stateIndex := stateDataEnd - dataSize; stateIndex >= stateDataStart; stateIndex -= dataSize {
 		for j := len(possibleStates) - 1; j >= 0; j-- {
 			prevState := BorlandRandPreviousSeed(possibleStates[j])
-			if _, output := BorlandRand(prevState); output != binary.LittleEndian.Uint16(data.KeyBlock()[stateIndex:]) {
+			if _, output := BorlandRand(prevState); uint32(output)&outputMask != readOutput(stateIndex) {
 				possibleStates = slices.Delete(possibleStates, j, j+1)
 				continue
 			}
@@ -112,88 +121,78 @@ func BruteforceBorlandSeed(b EncryptedBlock, material KeyMaterial) ([]uint32, er
 	slices.Sort(possibleStates)
 	possibleStates = slices.Compact(possibleStates)
 
-	return possibleStates, err
+	return possibleStates, nil
 }
 
-func BruteforceBorlandSeedBytes(b EncryptedBlock, material KeyMaterial) ([]uint32, error) {
+// BruteforceResult Seeds found for a single block of a batch
+type BruteforceResult struct {
+	Seeds []uint32
+	Err   error
+}
+
+// BruteforceBorlandSeedBatch Runs BruteforceBorlandSeed for each block, with blocks shared across workers.
+// progress, if set, is called with the number of blocks done so far
+func BruteforceBorlandSeedBatch(ctx context.Context, blocks []EncryptedBlock, material KeyMaterial, workers int, progress func(done, total uint64)) ([]BruteforceResult, error) {
+	return bruteforceBatch(ctx, blocks, workers, progress, func(b EncryptedBlock) ([]uint32, error) {
+		return BruteforceBorlandSeedContext(ctx, b, material, 1, nil)
+	})
+}
+
+// BruteforceBorlandSeedBytesBatch Runs BruteforceBorlandSeedBytes for each block, with blocks shared across workers.
+// progress, if set, is called with the number of blocks done so far
+func BruteforceBorlandSeedBytesBatch(ctx context.Context, blocks []EncryptedBlock, material KeyMaterial, workers int, progress func(done, total uint64)) ([]BruteforceResult, error) {
+	return bruteforceBatch(ctx, blocks, workers, progress, func(b EncryptedBlock) ([]uint32, error) {
+		return BruteforceBorlandSeedBytesContext(ctx, b, material, 1, nil)
+	})
+}
 
+func bruteforceBatch(ctx context.Context, blocks []EncryptedBlock, workers int, progress func(done, total uint64), bruteforce func(b EncryptedBlock) ([]uint32, error)) ([]BruteforceResult, error) {
+	results := make([]BruteforceResult, len(blocks))
+	err := utils.ParallelRange(ctx, uint64(len(blocks)), 1, workers, progress, func(start, end uint64) {
+		for i := start; i < end; i++ {
+			results[i].Seeds, results[i].Err = bruteforce(blocks[i])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BruteforceJavaRandSeed Finds JavaRandKeyGenerator states that generated the key of b.
+// Each nextInt() leaks the upper 32 bits of the 48-bit state, so only the lower 16 bits are searched
+func BruteforceJavaRandSeed(b EncryptedBlock, material KeyMaterial) ([]uint64, error) {
 	data := slices.Clone(b)
 	err := data.Decrypt(material, false)
 	if err != nil {
 		return nil, err
 	}
-	// Efficient search as LCG leaks 8 bits each time, by backwards looping and solving across uint24 range
 
-	const dataSize = 1
+	const dataSize = 4
+	const outputBits = dataSize * 8
 	const stateDataStart = EncryptedBlockMangleKeyOffset
-	const stateDataEnd = EncryptedBlockCRC1Offset - dataSize
-	const byteOutputMask = BorlandRandOutputMask & 0xFF
-	validStateBits := bits.OnesCount32(byteOutputMask)
-	validStateInverseBits := bits.OnesCount32(^byteOutputMask)
-	var stateOutputMask = byteOutputMask << BorlandRandOutputShift
-
-	statesBuf := make([]uint32, 0, 32-validStateBits)
-	getPossibleStates := func(state uint32, previousOutput uint8) (states []uint32) {
-		var seed, prevSeed uint32
+	const stateDataEnd = EncryptedBlockCRC1Offset
 
-		states = statesBuf[:0]
+	var possibleStates []uint64
 
-		var limit uint32 = 1 << validStateInverseBits
-		for n := uint32(0); n < limit; n++ {
-			// Fills the output part of the state
-			seed = (state & stateOutputMask) | ((n & (^uint32(0xFFFF))) << validStateBits) | (n & 0xFFFF)
+	firstOutput := uint64(binary.LittleEndian.Uint32(data[stateDataStart:]))
+	for n := uint64(0); n < 1<<(48-outputBits); n++ {
+		state := firstOutput<<(48-outputBits) | n
 
-			prevSeed = BorlandRandPreviousSeed(seed)
-
-			if uint8(BorlandRandOutput(prevSeed)) == previousOutput {
-				states = append(states, prevSeed)
+		valid := true
+		nextState := state
+		for i := stateDataStart + dataSize; i < stateDataEnd; i += dataSize {
+			nextState = JavaRandNextSeed(nextState)
+			if uint32(JavaRandOutputBits(nextState, outputBits)) != binary.LittleEndian.Uint32(data[i:]) {
+				valid = false
+				break
 			}
 		}
-		return states
-	}
 
-	var possibleStates []uint32
-
-	stateIndex := stateDataEnd
-
-	{
-		previousOutput := data.KeyBlock()[stateIndex-dataSize]
-		var nextStates []uint32
-		previousState := (uint32(data.KeyBlock()[stateIndex]) << BorlandRandOutputShift) & stateOutputMask
-		states := getPossibleStates(previousState, previousOutput)
-		if len(nextStates) == 0 {
-			slices.Sort(states)
-			states = slices.Compact(states)
-			nextStates = slices.Clone(states)
-		} else {
-			for i := len(nextStates) - 1; i >= 0; i-- {
-				if !slices.Contains(states, nextStates[i]) {
-					nextStates = slices.Delete(nextStates, i, i+1)
-				}
-			}
-		}
-
-		slices.Sort(nextStates)
-		nextStates = slices.Compact(nextStates)
-		possibleStates = nextStates
-
-		stateIndex -= dataSize
-	}
-
-	//Last rounds backwards with checks
-	for ; stateIndex >= stateDataStart; stateIndex -= dataSize {
-		for j := len(possibleStates) - 1; j >= 0; j-- {
-			prevState := BorlandRandPreviousSeed(possibleStates[j])
-			if _, output := BorlandRand(prevState); uint8(output) != data.KeyBlock()[stateIndex] {
-				possibleStates = slices.Delete(possibleStates, j, j+1)
-				continue
-			}
-			possibleStates[j] = prevState
+		if valid {
+			possibleStates = append(possibleStates, JavaRandPreviousSeed(state))
 		}
 	}
 
-	slices.Sort(possibleStates)
-	possibleStates = slices.Compact(possibleStates)
-
-	return possibleStates, err
+	return possibleStates, nil
 }
@@ -1,10 +1,13 @@
 package encryption
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"io"
 	"slices"
+	"sync/atomic"
 	"testing"
 )
 
@@ -121,3 +124,129 @@ func TestBruteforceSeed_FirmwareCompressed(t *testing.T) {
 		t.Fatal("error expected: \"not a borland rand seed\"")
 	}
 }
+
+func TestBruteforceJavaRandSeed(t *testing.T) {
+	t.Parallel()
+
+	var seedBuf [8]byte
+	if _, err := io.ReadFull(rand.Reader, seedBuf[:]); err != nil {
+		t.Fatal(err)
+	}
+	generator := NewJavaRandKeyGenerator(int64(binary.LittleEndian.Uint64(seedBuf[:])))
+	state := uint64(*generator)
+
+	b := NewEncryptedBlock(0)
+	material := NewMemoryKeyMaterial(generator)
+	if err := b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := BruteforceJavaRandSeed(b, material)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Contains(states, state) {
+		t.Fatalf("state %012x not found in %x", state, states)
+	}
+}
+
+func TestJavaRandKeyGenerator(t *testing.T) {
+	t.Parallel()
+
+	// Values as produced by java.util.Random
+	if v := NewJavaRandKeyGenerator(0).NextInt(); v != -1155484576 {
+		t.Fatalf("unexpected nextInt() %d", v)
+	}
+	if v := NewJavaRandKeyGenerator(42).NextInt(); v != -1170105035 {
+		t.Fatalf("unexpected nextInt() %d", v)
+	}
+	if v := NewJavaRandKeyGenerator(42).NextIntBound(10); v != 0 {
+		t.Fatalf("unexpected nextInt(10) %d", v)
+	}
+
+	// nextBytes() uses nextInt() little endian, discarding extra bytes
+	g1, g2 := NewJavaRandKeyGenerator(42), NewJavaRandKeyGenerator(42)
+	buf := make([]byte, 6)
+	g1.NextBytes(buf)
+	a, b := g2.NextInt(), g2.NextInt()
+	if binary.LittleEndian.Uint32(buf) != uint32(a) || binary.LittleEndian.Uint16(buf[4:]) != uint16(b) {
+		t.Fatal("unexpected nextBytes()")
+	}
+	if *g1 != *g2 {
+		t.Fatal("state mismatch")
+	}
+}
+
+func TestBruteforceBorlandSeedBytesContext(t *testing.T) {
+	t.Parallel()
+
+	const seed = 0x89abcdef
+	generator := BorlandRandByteKeyGenerator(seed)
+	material := NewMemoryKeyMaterial(&generator)
+	b := NewEncryptedBlock(0)
+	if err := b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	var lastDone atomic.Uint64
+	seeds, err := BruteforceBorlandSeedBytesContext(context.Background(), b, material, 4, func(done, total uint64) {
+		if total != 1<<24 {
+			t.Errorf("unexpected total %d", total)
+		}
+		for {
+			if last := lastDone.Load(); done <= last || lastDone.CompareAndSwap(last, done) {
+				break
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(seeds, seed) {
+		t.Fatal("seed not found")
+	}
+	if lastDone.Load() != 1<<24 {
+		t.Fatalf("progress did not complete, %d", lastDone.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = BruteforceBorlandSeedBytesContext(ctx, b, material, 4, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestBruteforceBorlandSeedBatch(t *testing.T) {
+	t.Parallel()
+
+	var blocks []EncryptedBlock
+	var seeds []uint32
+	for i := 0; i < 16; i++ {
+		var seedBuf [4]byte
+		if _, err := io.ReadFull(rand.Reader, seedBuf[:]); err != nil {
+			t.Fatal(err)
+		}
+		seed := binary.LittleEndian.Uint32(seedBuf[:])
+		generator := BorlandRandKeyGenerator(seed)
+		b := NewEncryptedBlock(0)
+		if err := b.Encrypt(NewMemoryKeyMaterial(&generator)); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+		seeds = append(seeds, seed)
+	}
+
+	results, err := BruteforceBorlandSeedBatch(context.Background(), blocks, NewMemoryKeyMaterial(nil), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if !slices.Contains(r.Seeds, seeds[i]) {
+			t.Fatalf("seed %08x not found for block %d", seeds[i], i)
+		}
+	}
+}
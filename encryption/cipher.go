@@ -0,0 +1,39 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+type mangleCipher struct {
+	schedule MangleKeySchedule
+}
+
+// NewMangleCipher Returns the Mangle cipher with key as a cipher.Block, for use with standard modes of operation
+func NewMangleCipher(key MangleKeyData) cipher.Block {
+	return &mangleCipher{
+		schedule: key.Schedule(),
+	}
+}
+
+func (c *mangleCipher) BlockSize() int {
+	return MangleKeyBlockSize
+}
+
+func (c *mangleCipher) Encrypt(dst, src []byte) {
+	if len(src) < MangleKeyBlockSize {
+		panic("input not full block")
+	} else if len(dst) < MangleKeyBlockSize {
+		panic("output not full block")
+	}
+	binary.LittleEndian.PutUint64(dst, c.schedule.EncryptBlock(binary.LittleEndian.Uint64(src)))
+}
+
+func (c *mangleCipher) Decrypt(dst, src []byte) {
+	if len(src) < MangleKeyBlockSize {
+		panic("input not full block")
+	} else if len(dst) < MangleKeyBlockSize {
+		panic("output not full block")
+	}
+	binary.LittleEndian.PutUint64(dst, c.schedule.DecryptBlock(binary.LittleEndian.Uint64(src)))
+}
@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestMangleCipher(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range append(HardcodedMangleTable[:], AlternateMangleTable[0], *sampleDeviceMangleKeyOffset6) {
+		block := NewMangleCipher(key)
+		if block.BlockSize() != MangleKeyBlockSize {
+			t.Fatalf("unexpected block size %d", block.BlockSize())
+		}
+
+		data := make([]byte, MangleKeyBlockSize*16)
+		if _, err := io.ReadFull(rand.Reader, data); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := slices.Clone(data)
+		key.Encrypt(expected)
+
+		encrypted := make([]byte, len(data))
+		for i := 0; i < len(data); i += MangleKeyBlockSize {
+			block.Encrypt(encrypted[i:], data[i:])
+		}
+		if bytes.Compare(encrypted, expected) != 0 {
+			t.Fatal("encrypted data does not match")
+		}
+
+		// In place
+		for i := 0; i < len(encrypted); i += MangleKeyBlockSize {
+			block.Decrypt(encrypted[i:], encrypted[i:])
+		}
+		if bytes.Compare(encrypted, data) != 0 {
+			t.Fatal("decrypted data does not match")
+		}
+	}
+}
+
+func TestMangleCipher_CBC(t *testing.T) {
+	t.Parallel()
+
+	var key MangleKeyData
+	iv := make([]byte, MangleKeyBlockSize)
+	data := make([]byte, MangleKeyBlockSize*16)
+	for _, buf := range [][]byte{key[:], iv, data} {
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block := NewMangleCipher(key)
+
+	encrypted := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+
+	// Decrypt manually with slice based functions
+	decrypted := slices.Clone(encrypted)
+	key.Decrypt(decrypted)
+	previous := iv
+	for i := 0; i < len(decrypted); i += MangleKeyBlockSize {
+		for j := 0; j < MangleKeyBlockSize; j++ {
+			decrypted[i+j] ^= previous[j]
+		}
+		previous = encrypted[i : i+MangleKeyBlockSize]
+	}
+	if bytes.Compare(decrypted, data) != 0 {
+		t.Fatal("decrypted data does not match")
+	}
+
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+	if bytes.Compare(decrypted, data) != 0 {
+		t.Fatal("decrypted data does not match")
+	}
+}
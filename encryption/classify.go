@@ -0,0 +1,153 @@
+package encryption
+
+import "slices"
+
+type KeyGeneratorType int
+
+const (
+	KeyGeneratorUnknown KeyGeneratorType = iota
+	KeyGeneratorZero
+	KeyGeneratorBorlandRand
+	KeyGeneratorBorlandRandByte
+	KeyGeneratorJavaRand
+	KeyGeneratorSecureRandom
+)
+
+var keyGeneratorTypeNames = map[KeyGeneratorType]string{
+	KeyGeneratorUnknown:         "Unknown",
+	KeyGeneratorZero:            "Zero",
+	KeyGeneratorBorlandRand:     "BorlandRand",
+	KeyGeneratorBorlandRandByte: "BorlandRandByte",
+	KeyGeneratorJavaRand:        "JavaRand",
+	KeyGeneratorSecureRandom:    "SecureRandom",
+}
+
+func (t KeyGeneratorType) String() string {
+	if name, ok := keyGeneratorTypeNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// stuckCounterSeeds BorlandRand seeds generated by devices with a faulted, static counter
+var stuckCounterSeeds = []uint32{0}
+
+// KeyClassification Likely generator of a key block
+type KeyClassification struct {
+	Generator KeyGeneratorType
+	// Confidence From 0 to 1. Generators with seeds are only reported once the full key block has been regenerated
+	Confidence float64
+	// Seeds Generator states before generating the key block, where applicable
+	Seeds []uint64
+	// Stuck Seed is known to come from a faulted, static counter, and the device generates the same key always
+	Stuck bool
+}
+
+// secureRandomMaxChiSquare Chi-square bound of byte frequencies across key and padding, around 5 standard deviations over the mean of 255
+const secureRandomMaxChiSquare = 255 + 5*22.6
+
+// ClassifyKeyBlock Decrypts b and checks its key and padding against each known KeyGenerator
+func ClassifyKeyBlock(b EncryptedBlock, material KeyMaterial) (result KeyClassification, err error) {
+	data := slices.Clone(b)
+	if err = data.Decrypt(material, false); err != nil {
+		return result, err
+	}
+
+	key := data[EncryptedBlockMangleKeyOffset:EncryptedBlockCRC1Offset]
+	padding := data[EncryptedBlockPaddingKeyOffset:EncryptedBlockKeySize]
+
+	if isZeroBytes(key) && isZeroBytes(padding) {
+		result.Generator = KeyGeneratorZero
+		result.Confidence = 1
+		return result, nil
+	}
+
+	if isKeyBorlandLikely(data) {
+		if seeds, err := BruteforceBorlandSeed(b, material); err == nil {
+			for _, seed := range seeds {
+				generator := BorlandRandKeyGenerator(seed)
+				if verifyKeyGenerator(data, &generator) {
+					result.Seeds = append(result.Seeds, uint64(seed))
+					result.Stuck = result.Stuck || slices.Contains(stuckCounterSeeds, seed)
+				}
+			}
+		}
+		if len(result.Seeds) > 0 {
+			result.Generator = KeyGeneratorBorlandRand
+			result.Confidence = 1
+			return result, nil
+		}
+	}
+
+	if seeds, err := BruteforceBorlandSeedBytes(b, material); err == nil {
+		for _, seed := range seeds {
+			generator := BorlandRandByteKeyGenerator(seed)
+			if verifyKeyGenerator(data, &generator) {
+				result.Seeds = append(result.Seeds, uint64(seed))
+			}
+		}
+		if len(result.Seeds) > 0 {
+			result.Generator = KeyGeneratorBorlandRandByte
+			result.Confidence = 1
+			return result, nil
+		}
+	}
+
+	if seeds, err := BruteforceJavaRandSeed(b, material); err == nil {
+		for _, seed := range seeds {
+			generator := JavaRandKeyGenerator(seed)
+			if verifyKeyGenerator(data, &generator) {
+				result.Seeds = append(result.Seeds, seed)
+			}
+		}
+		if len(result.Seeds) > 0 {
+			result.Generator = KeyGeneratorJavaRand
+			result.Confidence = 1
+			return result, nil
+		}
+	}
+
+	// No known generator, check byte frequencies are uniform
+	var frequencies [256]int
+	for _, v := range key {
+		frequencies[v]++
+	}
+	for _, v := range padding {
+		frequencies[v]++
+	}
+	expected := float64(len(key)+len(padding)) / float64(len(frequencies))
+	var chiSquare float64
+	for _, f := range frequencies {
+		chiSquare += (float64(f) - expected) * (float64(f) - expected) / expected
+	}
+
+	if chiSquare <= secureRandomMaxChiSquare {
+		result.Generator = KeyGeneratorSecureRandom
+		// Uniform output does not rule out unknown generators
+		result.Confidence = 0.9
+	}
+
+	return result, nil
+}
+
+// verifyKeyGenerator Whether generator produces the decrypted key and padding of data
+func verifyKeyGenerator(data EncryptedBlock, generator KeyGenerator) bool {
+	var key [MangleKeyDataSize]byte
+	generator.FillKeyBlock(key[:])
+	if !slices.Equal(key[:], data[EncryptedBlockMangleKeyOffset:EncryptedBlockCRC1Offset]) {
+		return false
+	}
+
+	padding := make([]byte, EncryptedBlockKeySize-EncryptedBlockPaddingKeyOffset)
+	generator.FillKeyBlock(padding)
+	return slices.Equal(padding, data[EncryptedBlockPaddingKeyOffset:EncryptedBlockKeySize])
+}
+
+func isZeroBytes(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
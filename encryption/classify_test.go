@@ -0,0 +1,56 @@
+package encryption
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClassifyKeyBlock(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Generator KeyGenerator
+		Expected  KeyGeneratorType
+		Seed      uint64
+	}{
+		{&ZeroKeyGenerator{}, KeyGeneratorZero, 0},
+		{func() KeyGenerator { g := BorlandRandKeyGenerator(0xdeadbeef); return &g }(), KeyGeneratorBorlandRand, 0xdeadbeef},
+		{func() KeyGenerator { g := BorlandRandByteKeyGenerator(0x1234567); return &g }(), KeyGeneratorBorlandRandByte, 0x1234567},
+		{NewJavaRandKeyGenerator(42), KeyGeneratorJavaRand, JavaRandScrambleSeed(42)},
+		{&SecureRandomKeyGenerator{}, KeyGeneratorSecureRandom, 0},
+	} {
+		t.Run(tc.Expected.String(), func(t *testing.T) {
+			material := NewMemoryKeyMaterial(tc.Generator)
+			b := NewEncryptedBlock(8)
+			if err := b.Encrypt(material); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := ClassifyKeyBlock(b, material)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Generator != tc.Expected || result.Confidence <= 0 {
+				t.Fatalf("expected %s, got %s with confidence %f", tc.Expected, result.Generator, result.Confidence)
+			}
+			if result.Seeds != nil && !slices.Contains(result.Seeds, tc.Seed) {
+				t.Fatalf("seed %x not found in %x", tc.Seed, result.Seeds)
+			}
+			if result.Stuck {
+				t.Fatal("unexpected stuck counter")
+			}
+		})
+	}
+}
+
+func TestClassifyKeyBlock_Stuck(t *testing.T) {
+	t.Parallel()
+
+	result, err := ClassifyKeyBlock(sampleBlockMemoryEmpty, NewMemoryKeyMaterial(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Generator != KeyGeneratorBorlandRand || !result.Stuck {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,192 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"slices"
+	"sort"
+)
+
+// Detection scores, added up for each check a candidate passes
+const (
+	DetectionScoreMangleIndex = 1
+	DetectionScoreBorland     = 2
+	DetectionScoreCRCPair     = 4
+	DetectionScoreDataCRC     = 8
+)
+
+// DetectionResult Candidate KeyMaterial for an EncryptedBlock, and the checks it passed
+type DetectionResult struct {
+	Material    KeyMaterial
+	MangleIndex uint32
+
+	CRCPair bool
+	DataCRC bool
+	// Compressed Data CRC only matched after compression.FirmwareBlockDecompress
+	Compressed bool
+	// BorlandLikely See IsKeyBorlandSeedLikely
+	BorlandLikely bool
+
+	Score int
+}
+
+// DetectKeyMaterial Tries all outer key offsets with each candidate KeyMaterial, or default key tables if none are given.
+// Results with a valid mangle index are returned, ranked by Score
+func DetectKeyMaterial(b EncryptedBlock, candidates ...KeyMaterial) (results []DetectionResult) {
+	if len(candidates) == 0 {
+		candidates = []KeyMaterial{{}}
+	}
+
+	for _, candidate := range candidates {
+		for offset := range HardcodedMangleTable {
+			material := candidate
+			material.Generator = nil
+			material.OuterKeyOffset = OuterMangleKeyOffset(offset)
+
+			if result, ok := detectKeyMaterial(b, material); ok {
+				results = append(results, result)
+			}
+		}
+	}
+
+	slices.SortStableFunc(results, func(a, b DetectionResult) int {
+		return b.Score - a.Score
+	})
+
+	return results
+}
+
+func detectKeyMaterial(b EncryptedBlock, material KeyMaterial) (result DetectionResult, ok bool) {
+	data := slices.Clone(b)
+
+	result.Material = material
+
+	err := data.Decrypt(material, false)
+	result.MangleIndex = data.MangleIndex()
+	if result.MangleIndex > MangleIndexAlternateKey7 && (result.MangleIndex != MangleIndexDeviceKey || material.DeviceKey == nil) {
+		return result, false
+	}
+	result.Score += DetectionScoreMangleIndex
+
+	if isKeyBorlandLikely(data) {
+		result.BorlandLikely = true
+		result.Score += DetectionScoreBorland
+	}
+
+	if err != nil {
+		return result, true
+	}
+	result.CRCPair = true
+	result.Score += DetectionScoreCRCPair
+
+	crc1, _ := data.CRC()
+	if material.CalculateCRC(data.DataBlock()) == crc1 {
+		result.DataCRC = true
+	} else if _, _, ok := findCompressedSize(data.DataBlock(), crc1, material); ok {
+		result.DataCRC = true
+		result.Compressed = true
+	}
+
+	if result.DataCRC {
+		result.Score += DetectionScoreDataCRC
+	}
+
+	return result, true
+}
+
+// isKeyBorlandLikely Checks decrypted key and padding for BorlandRand output, with the highest bit unset
+func isKeyBorlandLikely(data EncryptedBlock) bool {
+	for i := EncryptedBlockMangleKeyOffset; i < EncryptedBlockCRC1Offset; i += 2 {
+		if binary.LittleEndian.Uint16(data[i:])&0x8000 > 0 {
+			return false
+		}
+	}
+
+	for i := EncryptedBlockPaddingKeyOffset; i < EncryptedBlockKeySize; i += 2 {
+		if binary.LittleEndian.Uint16(data[i:])&0x8000 > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findCompressedSize Finds the length of decrypted that decompresses into data whose CRC matches crc1. Shared by DetectKeyMaterial and ScanBlocks
+func findCompressedSize(decrypted []byte, crc1 uint32, material KeyMaterial) (size int, decompressed []byte, ok bool) {
+	// Output only grows with longer input, so find the longest input not exceeding compression.DataMaxSize
+	size = sort.Search(len(decrypted), func(i int) bool {
+		_, err := compression.FirmwareBlockDecompress(decrypted[:i+1])
+		return errors.Is(err, compression.ErrOutOfBounds)
+	})
+
+	// Input may end within a code
+	var output []byte
+	for ; size > 0; size-- {
+		var err error
+		if output, err = compression.FirmwareBlockDecompress(decrypted[:size]); err == nil {
+			break
+		}
+	}
+
+	// Output of shorter input is a prefix of output, so all data lengths can be checked at once
+	n := findDecompressedSize(output, crc1, material)
+	if n < 0 {
+		return 0, nil, false
+	}
+
+	// Shortest input producing the whole data. Compressed data ends on a byte boundary, so it decodes without error,
+	// while shorter input may end within a code and is extended up to a byte where it does not
+	decompressedSize := func(length int) int {
+		for ; length <= len(decrypted); length++ {
+			if data, err := compression.FirmwareBlockDecompress(decrypted[:length]); err == nil {
+				return len(data)
+			}
+		}
+		return 0
+	}
+	size = 1 + sort.Search(size, func(i int) bool {
+		return decompressedSize(i+1) >= n
+	})
+	for ; size <= len(decrypted); size++ {
+		if data, err := compression.FirmwareBlockDecompress(decrypted[:size]); err == nil && len(data) >= n {
+			if size%MangleKeyBlockSize != 0 {
+				size += MangleKeyBlockSize - size%MangleKeyBlockSize
+			}
+			return size, output[:n], true
+		}
+	}
+
+	return 0, nil, false
+}
+
+// findDecompressedSize Finds the length of output whose CRC matches crc1, or -1
+func findDecompressedSize(output []byte, crc1 uint32, material KeyMaterial) int {
+	if material.CRC != nil {
+		for n := 1; n <= len(output); n++ {
+			if material.CRC(output[:n]) == crc1 {
+				return n
+			}
+		}
+		return -1
+	}
+
+	// Default CRC zero pads the last partial word, so it is calculated over a copy
+	c := crc.NewCRC()
+	for n := 1; n <= len(output); n++ {
+		partial := c
+		partial.Update(output[n-(n-1)%4-1 : n])
+		if partial.Sum32() == crc1 {
+			// Trailing zero bytes up to a word boundary do not change the CRC, prefer the longer length
+			for n%4 != 0 && n < len(output) && output[n] == 0 {
+				n++
+			}
+			return n
+		}
+		if n%4 == 0 {
+			c = partial
+		}
+	}
+	return -1
+}
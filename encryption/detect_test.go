@@ -0,0 +1,132 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"io"
+	"testing"
+)
+
+func TestDetectKeyMaterial_Firmware(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Name       string
+		Block      EncryptedBlock
+		Compressed bool
+	}{
+		{"Uncompressed", sampleBlockFirmwareUncompressed, false},
+		{"Compressed", sampleBlockFirmwareCompressed, true},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			results := DetectKeyMaterial(tc.Block)
+			if len(results) == 0 {
+				t.Fatal("no results")
+			}
+
+			best := results[0]
+			if best.Material.OuterKeyOffset != OuterMangleKeyOffsetFlash || !best.CRCPair || !best.DataCRC {
+				t.Fatalf("unexpected result %+v", best)
+			}
+			if best.Compressed != tc.Compressed {
+				t.Fatalf("expected compressed %v", tc.Compressed)
+			}
+		})
+	}
+}
+
+func TestDetectKeyMaterial_CompressedPadding(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 0x800)
+	if _, err := io.ReadFull(rand.Reader, data[:0x123]); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := compression.FirmwareBlockCompress(data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Random padding after compressed data, which may decompress into many trailing bytes
+	b := NewEncryptedBlock(int(PaddedSize(int64(len(payload)))) + 0x200)
+	if _, err = io.ReadFull(rand.Reader, b.DataBlock()); err != nil {
+		t.Fatal(err)
+	}
+	copy(b.DataBlock(), payload)
+	material := NewFlashKeyMaterial(&SecureRandomKeyGenerator{})
+	material.CRC = func([]byte) uint32 {
+		return crc.CalculateCRC(data)
+	}
+	if err = b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	results := DetectKeyMaterial(b)
+	if len(results) == 0 || !results[0].DataCRC || !results[0].Compressed {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	// Both detectors must agree
+	if scan := ScanBlocks(b, len(b.DataBlock())); len(scan) != 1 || !bytes.Equal(scan[0].Decompressed, data) {
+		t.Fatal("expected ScanBlocks to find compressed block")
+	}
+}
+
+func TestDetectKeyMaterial_Memory(t *testing.T) {
+	t.Parallel()
+
+	b := NewEncryptedBlock(256)
+	if _, err := io.ReadFull(rand.Reader, b.DataBlock()); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := BorlandRandKeyGenerator(0x12345678)
+	if err := b.Encrypt(NewMemoryKeyMaterial(&generator)); err != nil {
+		t.Fatal(err)
+	}
+
+	results := DetectKeyMaterial(b)
+	if len(results) == 0 {
+		t.Fatal("no results")
+	}
+
+	best := results[0]
+	if best.Material.OuterKeyOffset != OuterMangleKeyOffsetMemory || !best.DataCRC || best.Compressed || !best.BorlandLikely {
+		t.Fatalf("unexpected result %+v", best)
+	}
+	if best.Score != DetectionScoreMangleIndex+DetectionScoreBorland+DetectionScoreCRCPair+DetectionScoreDataCRC {
+		t.Fatalf("unexpected score %d", best.Score)
+	}
+	for _, r := range results[1:] {
+		if r.CRCPair {
+			t.Fatalf("unexpected CRC pair match %+v", r)
+		}
+	}
+}
+
+func TestDetectKeyMaterial_DeviceKey(t *testing.T) {
+	t.Parallel()
+
+	material := NewFlashKeyMaterial(NewMangleIndexGeneratorWrapper(&SecureRandomKeyGenerator{}, MangleIndexDeviceKey))
+	material.OuterKeyOffset = OuterMangleKeyOffsetDeviceId
+	material.DeviceKey = DeviceMangleKeyOffset6(testDeviceId)
+
+	b := NewEncryptedBlock(64)
+	if err := b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without device key, no candidate can decrypt it
+	for _, r := range DetectKeyMaterial(b) {
+		if r.CRCPair {
+			t.Fatalf("unexpected CRC pair match %+v", r)
+		}
+	}
+
+	results := DetectKeyMaterial(b, KeyMaterial{}, KeyMaterial{DeviceKey: material.DeviceKey})
+	if best := results[0]; best.Material.OuterKeyOffset != OuterMangleKeyOffsetDeviceId || best.MangleIndex != MangleIndexDeviceKey || !best.DataCRC {
+		t.Fatalf("unexpected result %+v", best)
+	}
+}
@@ -5,8 +5,11 @@ import "encoding/binary"
 type DeviceId [3]uint32
 
 func DeviceMangleKeyOffset6(deviceId DeviceId) *MangleKeyData {
+	return deviceMangleKeyOffset6(HardcodedMangleTable[OuterMangleKeyOffsetDeviceId], deviceId)
+}
+
+func deviceMangleKeyOffset6(outerKey MangleKeyData, deviceId DeviceId) *MangleKeyData {
 	var d MangleKeyData
-	outerKey := HardcodedMangleTable[OuterMangleKeyOffsetDeviceId]
 	binary.LittleEndian.PutUint32(d[:], deviceId[0]^outerKey.RoundKey(0))
 	binary.LittleEndian.PutUint32(d[4:], deviceId[1]^outerKey.RoundKey(1))
 	binary.LittleEndian.PutUint32(d[8:], deviceId[2]^outerKey.RoundKey(2))
@@ -16,8 +19,11 @@ func DeviceMangleKeyOffset6(deviceId DeviceId) *MangleKeyData {
 }
 
 func DeviceMangleKeyOffset0(deviceId DeviceId) *MangleKeyData {
+	return deviceMangleKeyOffset0(HardcodedMangleTable[OuterMangleKeyOffsetDefault], deviceId)
+}
+
+func deviceMangleKeyOffset0(outerKey MangleKeyData, deviceId DeviceId) *MangleKeyData {
 	var d MangleKeyData
-	outerKey := HardcodedMangleTable[OuterMangleKeyOffsetDefault]
 	binary.LittleEndian.PutUint32(d[:], deviceId[0]^outerKey.RoundKey(0))
 	binary.LittleEndian.PutUint32(d[4:], deviceId[1]^outerKey.RoundKey(1))
 	binary.LittleEndian.PutUint32(d[8:], deviceId[2]^outerKey.RoundKey(2))
@@ -28,20 +34,41 @@ func DeviceMangleKeyOffset0(deviceId DeviceId) *MangleKeyData {
 
 // DecryptDeviceCode Decrypts code specifically encrypted to only work on a specific device id
 func DecryptDeviceCode(deviceId DeviceId, code []byte) []byte {
+	return decryptDeviceCode(DeviceMangleKeyOffset0(deviceId), code)
+}
+
+func decryptDeviceCode(key *MangleKeyData, code []byte) []byte {
 	if len(code)%8 != 0 {
 		panic("len must be % 8")
 	}
-	DeviceMangleKeyOffset0(deviceId).Decrypt(code)
+	key.Decrypt(code)
 
 	return code
 }
 
 // EncryptDeviceCode Encrypt code specifically encrypted to only work on a specific device id
 func EncryptDeviceCode(deviceId DeviceId, code []byte) []byte {
+	return encryptDeviceCode(DeviceMangleKeyOffset0(deviceId), code)
+}
+
+func encryptDeviceCode(key *MangleKeyData, code []byte) []byte {
 	if len(code)%8 != 0 {
 		panic("len must be % 8")
 	}
-	DeviceMangleKeyOffset0(deviceId).Encrypt(code)
+	key.Encrypt(code)
 
 	return code
 }
+
+// DeviceIdFromMangleKeyOffset0 Inverse of DeviceMangleKeyOffset0. The fourth key word duplicates ^deviceId[0], so keys not derived from a device id are rejected
+func DeviceIdFromMangleKeyOffset0(key MangleKeyData) (deviceId DeviceId, ok bool) {
+	return deviceIdFromMangleKeyOffset0(HardcodedMangleTable[OuterMangleKeyOffsetDefault], key)
+}
+
+func deviceIdFromMangleKeyOffset0(outerKey, key MangleKeyData) (deviceId DeviceId, ok bool) {
+	deviceId[0] = key.RoundKey(0) ^ outerKey.RoundKey(0)
+	deviceId[1] = key.RoundKey(1) ^ outerKey.RoundKey(1)
+	deviceId[2] = key.RoundKey(2) ^ outerKey.RoundKey(2)
+
+	return deviceId, key.RoundKey(3)^outerKey.RoundKey(3) == ^deviceId[0]
+}
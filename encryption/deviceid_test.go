@@ -84,3 +84,64 @@ func TestDecryptDeviceCode_B(t *testing.T) {
 		t.Fatalf("expected CRC %08x, got %08x", expectedCRC, crcValue)
 	}
 }
+
+func TestDeviceIdFromMangleKeyOffset0(t *testing.T) {
+	t.Parallel()
+
+	deviceId, ok := DeviceIdFromMangleKeyOffset0(*DeviceMangleKeyOffset0(testDeviceId))
+	if !ok || deviceId != testDeviceId {
+		t.Fatalf("expected %08x, got %08x", testDeviceId, deviceId)
+	}
+
+	if _, ok = DeviceIdFromMangleKeyOffset0(*sampleDeviceMangleKeyOffset6); ok {
+		t.Fatal("expected key to be rejected")
+	}
+}
+
+func TestParseSTM32UID(t *testing.T) {
+	t.Parallel()
+
+	u := ParseSTM32UID(testDeviceId)
+	t.Log(u)
+
+	if string(u.Lot[:]) != "PKMU42 " || u.Wafer != 2 || u.X != 0x56 || u.Y != 0x3b {
+		t.Fatalf("unexpected fields %s", u)
+	}
+	if !u.Plausible() {
+		t.Fatal("expected plausible")
+	}
+	if u.DeviceId() != testDeviceId {
+		t.Fatal("round trip mismatch")
+	}
+
+	space := NewSTM32UIDSpace("PKMU4")
+	for i := uint64(0); i < space.Size(); i += space.Size() / 997 {
+		if !space.UID(i).Plausible() {
+			t.Fatalf("implausible uid %s", space.UID(i))
+		}
+	}
+
+	space.WaferMax = 3
+	space.XMin, space.XMax = 0x50, 0x60
+	space.YMin, space.YMax = 0x30, 0x40
+	found := false
+	for i := uint64(0); i < space.Size() && !found; i++ {
+		found = space.DeviceId(i) == testDeviceId
+	}
+	if !found {
+		t.Fatal("device id not in space")
+	}
+	if err := space.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []STM32UIDSpace{
+		{Lot: "PKMU42 X"},
+		{WaferMin: 2, WaferMax: 1},
+		{LotCharset: string(make([]byte, 0x1000)), XMax: 0xffff, YMax: 0xffff},
+	} {
+		if invalid.Validate() == nil {
+			t.Fatalf("expected invalid space %+v", invalid)
+		}
+	}
+}
@@ -70,6 +70,66 @@ func (g *BorlandRandByteKeyGenerator) MangleIndex() uint32 {
 	return mangleIndex
 }
 
+// JavaRandKeyGenerator Generates random numbers as java.util.Random, with its value as current internal seed.
+// Key blocks are filled via nextBytes(), and mangle index via nextInt(8)
+type JavaRandKeyGenerator uint64
+
+// NewJavaRandKeyGenerator Seeded as new java.util.Random(seed)
+func NewJavaRandKeyGenerator(seed int64) *JavaRandKeyGenerator {
+	g := JavaRandKeyGenerator(JavaRandScrambleSeed(seed))
+	return &g
+}
+
+func (g *JavaRandKeyGenerator) next(bits int) int32 {
+	seed := JavaRandNextSeed(uint64(*g))
+	*g = JavaRandKeyGenerator(seed)
+	return int32(JavaRandOutputBits(seed, bits))
+}
+
+// NextInt java.util.Random nextInt()
+func (g *JavaRandKeyGenerator) NextInt() int32 {
+	return g.next(32)
+}
+
+// NextIntBound java.util.Random nextInt(bound)
+func (g *JavaRandKeyGenerator) NextIntBound(bound int32) int32 {
+	if bound <= 0 {
+		panic("bound must be positive")
+	}
+
+	r := g.next(31)
+	m := bound - 1
+	if bound&m == 0 {
+		return int32((int64(bound) * int64(r)) >> 31)
+	}
+	for u := r; ; u = g.next(31) {
+		// Rejects values from the last partial range, relying on int32 overflow
+		if r = u % bound; u-r+m >= 0 {
+			return r
+		}
+	}
+}
+
+// NextBytes java.util.Random nextBytes(data)
+func (g *JavaRandKeyGenerator) NextBytes(data []byte) {
+	for i := 0; i < len(data); {
+		rnd := g.NextInt()
+		for n := min(len(data)-i, 4); n > 0; n-- {
+			data[i] = byte(rnd)
+			rnd >>= 8
+			i++
+		}
+	}
+}
+
+func (g *JavaRandKeyGenerator) FillKeyBlock(data []byte) {
+	g.NextBytes(data)
+}
+
+func (g *JavaRandKeyGenerator) MangleIndex() uint32 {
+	return uint32(g.NextIntBound(8))
+}
+
 // ZeroKeyGenerator Always outputs zero
 type ZeroKeyGenerator struct{}
 
@@ -1,5 +1,7 @@
 package encryption
 
+import "git.gammaspectra.live/WeebDataHoarder/PhytonUtils/math"
+
 // JavaRandMultiplier 25214903917
 const JavaRandMultiplier uint64 = 0x5DEECE66D
 
@@ -13,7 +15,16 @@ const JavaRandModulus = (1 << 48) - 1
 // JavaRandMultiplierInverse Calculated using math.ModularMultiplicativeInverseBits(uint64(JavaRandMultiplier), 48)
 const JavaRandMultiplierInverse uint64 = 0xdfe05bcb1365
 
-// JavaRand Borland java.util.Random
+// JavaRandLCG Generic form, to jump ahead or compute distance between seeds
+var JavaRandLCG = math.LCG{
+	Multiplier:  JavaRandMultiplier,
+	Addend:      JavaRandAddend,
+	ModulusBits: 48,
+	OutputShift: JavaRandOutputShift,
+	OutputMask:  JavaRandOutputMask,
+}
+
+// JavaRand java.util.Random
 func JavaRand(seed uint64) (newSeed uint64, output uint16) {
 	newSeed = JavaRandNextSeed(seed)
 	return newSeed, JavaRandOutput(newSeed)
@@ -27,6 +38,11 @@ func JavaRandOutputBits(seed uint64, bits int) uint64 {
 	return seed >> (48 - bits)
 }
 
+// JavaRandScrambleSeed Internal seed of new java.util.Random(seed)
+func JavaRandScrambleSeed(seed int64) uint64 {
+	return (uint64(seed) ^ JavaRandMultiplier) & JavaRandModulus
+}
+
 func JavaRandNextSeed(seed uint64) uint64 {
 	return (seed*JavaRandMultiplier + JavaRandAddend) & JavaRandModulus
 }
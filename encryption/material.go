@@ -1,10 +1,14 @@
 package encryption
 
+import "git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+
 type KeyMaterial struct {
 	// Generator Random source to generate the inline material
-	Generator         KeyGenerator
-	OuterKeyOffset    OuterMangleKeyOffset
-	DeviceKey         *MangleKeyData
+	Generator      KeyGenerator
+	OuterKeyOffset OuterMangleKeyOffset
+	DeviceKey      *MangleKeyData
+	// KeyTable Table for outer keys and normal mangle indices. If none is set, HardcodedMangleTable is used
+	KeyTable          *MangleKeyTable
 	AlternateKeyTable *MangleKeyTable
 
 	// CRC method to calculate CRC. If none is set, default is used.
@@ -29,6 +33,48 @@ func NewMemoryKeyMaterial(generator KeyGenerator) KeyMaterial {
 	}
 }
 
+// NewDeviceFlashKeyMaterial Flash blocks locked to a device via MangleIndexDeviceKey. generator may be nil when only decrypting
+func NewDeviceFlashKeyMaterial(generator KeyGenerator, deviceId DeviceId) KeyMaterial {
+	if generator != nil {
+		generator = NewMangleIndexGeneratorWrapper(generator, MangleIndexDeviceKey)
+	}
+	return KeyMaterial{
+		Generator:         generator,
+		OuterKeyOffset:    OuterMangleKeyOffsetFlash,
+		DeviceKey:         DeviceMangleKeyOffset6(deviceId),
+		AlternateKeyTable: nil,
+	}
+}
+
+// keyTable Returns KeyTable, or HardcodedMangleTable if unset
+func (m KeyMaterial) keyTable() *MangleKeyTable {
+	if m.KeyTable == nil {
+		return &HardcodedMangleTable
+	}
+	return m.KeyTable
+}
+
+// alternateKeyTable Returns AlternateKeyTable, or AlternateMangleTable if unset
+func (m KeyMaterial) alternateKeyTable() *MangleKeyTable {
+	if m.AlternateKeyTable == nil {
+		return &AlternateMangleTable
+	}
+	return m.AlternateKeyTable
+}
+
+// CalculateCRC CRC of data using CRC, or crc.CalculateCRC if unset
+func (m KeyMaterial) CalculateCRC(data []byte) uint32 {
+	if m.CRC != nil {
+		return m.CRC(data)
+	}
+	return crc.CalculateCRC(data)
+}
+
+// OuterKey Key used for outer mangle of the key block
+func (m KeyMaterial) OuterKey() MangleKeyData {
+	return m.keyTable()[m.OuterKeyOffset]
+}
+
 type OuterMangleKeyOffset int
 
 const (
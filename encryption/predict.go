@@ -0,0 +1,77 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+)
+
+// BorlandRandCallsPerBlock rand() calls made by BorlandRandKeyGenerator for each encrypted block: key, padding and mangle index
+const BorlandRandCallsPerBlock = MangleKeyDataSize/2 + (EncryptedBlockKeySize-EncryptedBlockPaddingKeyOffset)/2 + 1
+
+// PredictedKey Key block generated by BorlandRandKeyGenerator from Seed
+type PredictedKey struct {
+	Seed        uint32
+	Key         MangleKeyData
+	MangleIndex uint32
+	// KeyBlock Whole key block as decrypted, with mangle index, key and padding. CRC fields depend on data and are left zero
+	KeyBlock [EncryptedBlockKeySize]byte
+}
+
+// PredictKeys Key blocks a device generates for its next n encryptions from seed, when no other rand() calls happen in between.
+// Blocks can be forged with PredictedKey.Block
+func PredictKeys(seed uint32, n int) (keys []PredictedKey) {
+	generator := BorlandRandKeyGenerator(seed)
+	for i := 0; i < n; i++ {
+		k := PredictedKey{Seed: uint32(generator)}
+		generator.FillKeyBlock(k.KeyBlock[EncryptedBlockMangleKeyOffset:EncryptedBlockCRC1Offset])
+		generator.FillKeyBlock(k.KeyBlock[EncryptedBlockPaddingKeyOffset:])
+		k.MangleIndex = generator.MangleIndex()
+		binary.LittleEndian.PutUint16(k.KeyBlock[EncryptedBlockMangleIndexOffset:], uint16(k.MangleIndex))
+		k.Key = MangleKeyData(k.KeyBlock[EncryptedBlockMangleKeyOffset:EncryptedBlockCRC1Offset])
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Block Forges the block a device encrypts data into with k, byte for byte. material.Generator is not used.
+// data must be % 8, as is block data
+func (k *PredictedKey) Block(data []byte, material KeyMaterial) (EncryptedBlock, error) {
+	b := NewEncryptedBlock(len(data))
+	copy(b.KeyBlock(), k.KeyBlock[:])
+	copy(b.DataBlock(), data)
+
+	crcValue := material.CalculateCRC(b.DataBlock())
+	binary.LittleEndian.PutUint32(b[EncryptedBlockCRC1Offset:], crcValue)
+	binary.LittleEndian.PutUint32(b[EncryptedBlockCRC2Offset:], crcValue)
+
+	// Mangle of data
+	b.MangleKey().Encrypt(b.DataBlock())
+
+	if err := b.encryptKeyBlock(material, k.MangleIndex); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// VerifyPrediction Checks whether b was generated from seed after up to maxCalls rand() calls, and returns the number of calls
+func VerifyPrediction(b EncryptedBlock, material KeyMaterial, seed uint32, maxCalls uint64) (calls uint64, err error) {
+	data := slices.Clone(b)
+	if err = data.Decrypt(material, false); err != nil {
+		return 0, err
+	}
+
+	firstOutput := binary.LittleEndian.Uint16(data[EncryptedBlockMangleKeyOffset:])
+	for ; calls <= maxCalls; calls++ {
+		// Checks first output before regenerating the whole key block
+		if BorlandRandOutput(BorlandRandNextSeed(seed)) == firstOutput {
+			generator := BorlandRandKeyGenerator(seed)
+			if verifyKeyGenerator(data, &generator) {
+				return calls, nil
+			}
+		}
+		seed = BorlandRandNextSeed(seed)
+	}
+
+	return 0, errors.New("no prediction matches")
+}
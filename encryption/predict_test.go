@@ -0,0 +1,83 @@
+package encryption
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPredictKeys(t *testing.T) {
+	t.Parallel()
+
+	const seed = 0x0badf00d
+	predictions := PredictKeys(seed, 5)
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	generator := BorlandRandKeyGenerator(seed)
+	material := NewFlashKeyMaterial(&generator)
+	for i, p := range predictions {
+		if p.Seed != uint32(generator) {
+			t.Fatalf("seed mismatch at %d", i)
+		}
+
+		b := NewEncryptedBlock(len(data))
+		copy(b.DataBlock(), data)
+		if err := b.Encrypt(material); err != nil {
+			t.Fatal(err)
+		}
+
+		forged, err := p.Block(data, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(forged, b) {
+			t.Fatalf("forged block mismatch at %d", i)
+		}
+
+		if err = b.Decrypt(material, true); err != nil {
+			t.Fatal(err)
+		}
+		if b.MangleKey() != p.Key || b.MangleIndex() != p.MangleIndex {
+			t.Fatalf("prediction mismatch at %d", i)
+		}
+
+		keyBlock := slices.Clone(b.KeyBlock())
+		clear(keyBlock[EncryptedBlockCRC1Offset:EncryptedBlockPaddingKeyOffset])
+		if !slices.Equal(keyBlock, p.KeyBlock[:]) {
+			t.Fatalf("key block mismatch at %d", i)
+		}
+	}
+
+	if uint32(generator) != uint32(BorlandRandLCG.Jump(seed, 5*BorlandRandCallsPerBlock)) {
+		t.Fatal("unexpected rand() calls per block")
+	}
+}
+
+func TestVerifyPrediction(t *testing.T) {
+	t.Parallel()
+
+	const seed = 0x0badf00d
+	const gap = 1234
+
+	generator := BorlandRandKeyGenerator(BorlandRandLCG.Jump(seed, gap))
+	material := NewMemoryKeyMaterial(&generator)
+	b := NewEncryptedBlock(8)
+	if err := b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := VerifyPrediction(b, material, seed, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != gap {
+		t.Fatalf("expected %d calls, got %d", gap, calls)
+	}
+
+	if _, err = VerifyPrediction(b, material, seed, gap-1); err == nil {
+		t.Fatal("expected no match")
+	}
+}
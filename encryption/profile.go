@@ -0,0 +1,296 @@
+package encryption
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// DefaultKeyProfileName Name of the default profile, also selected by an empty name
+const DefaultKeyProfileName = "default"
+
+// CRCVariantDefault Name of crc.CalculateCRC as a CRC variant
+const CRCVariantDefault = "default"
+
+// KeyProfile Key tables and outer key offsets used by a product line
+type KeyProfile struct {
+	Name string `json:"name"`
+
+	KeyTable          MangleKeyTable `json:"key_table"`
+	AlternateKeyTable MangleKeyTable `json:"alternate_key_table"`
+
+	OuterKeyOffsetFlash    OuterMangleKeyOffset `json:"outer_key_offset_flash"`
+	OuterKeyOffsetMemory   OuterMangleKeyOffset `json:"outer_key_offset_memory"`
+	OuterKeyOffsetDeviceId OuterMangleKeyOffset `json:"outer_key_offset_device_id"`
+	// OuterKeyOffsetDeviceCode Offset of key used by DeviceMangleKeyOffset0
+	OuterKeyOffsetDeviceCode OuterMangleKeyOffset `json:"outer_key_offset_device_code"`
+
+	// CRC Name of a CRC variant registered via RegisterCRCVariant. If empty, CRCVariantDefault is used
+	CRC string `json:"crc,omitempty"`
+}
+
+// defaultKeyProfile Current hardcoded tables and offsets. Only copies are handed out, see DefaultKeyProfile
+var defaultKeyProfile = KeyProfile{
+	Name:                     DefaultKeyProfileName,
+	KeyTable:                 HardcodedMangleTable,
+	AlternateKeyTable:        AlternateMangleTable,
+	OuterKeyOffsetFlash:      OuterMangleKeyOffsetFlash,
+	OuterKeyOffsetMemory:     OuterMangleKeyOffsetMemory,
+	OuterKeyOffsetDeviceId:   OuterMangleKeyOffsetDeviceId,
+	OuterKeyOffsetDeviceCode: OuterMangleKeyOffsetDefault,
+	CRC:                      CRCVariantDefault,
+}
+
+var profilesLock sync.RWMutex
+var profiles = map[string]*KeyProfile{
+	DefaultKeyProfileName: &defaultKeyProfile,
+}
+
+var crcVariants = map[string]func(data []byte) uint32{
+	CRCVariantDefault: nil,
+}
+
+// RegisterCRCVariant Makes fn selectable by name in KeyProfile.CRC. A nil fn selects the default CRC
+func RegisterCRCVariant(name string, fn func(data []byte) uint32) {
+	profilesLock.Lock()
+	defer profilesLock.Unlock()
+	crcVariants[name] = fn
+}
+
+// RegisterKeyProfile Makes profile selectable by name, replacing any existing profile of the same name.
+// An omitted AlternateKeyTable is filled from AlternateMangleTable. DefaultKeyProfileName cannot be replaced
+func RegisterKeyProfile(profile KeyProfile) error {
+	profile.fillDefaults()
+	if err := profile.validateRegistration(); err != nil {
+		return err
+	}
+
+	profilesLock.Lock()
+	defer profilesLock.Unlock()
+	profiles[profile.Name] = &profile
+	return nil
+}
+
+// DefaultKeyProfile Returns a copy of the current hardcoded tables and offsets, as GetKeyProfile("")
+func DefaultKeyProfile() *KeyProfile {
+	profile := defaultKeyProfile
+	return &profile
+}
+
+// GetKeyProfile Returns a copy of the registered profile with name, or of DefaultKeyProfile if name is empty
+func GetKeyProfile(name string) (*KeyProfile, error) {
+	if name == "" {
+		name = DefaultKeyProfileName
+	}
+
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+	if profile, ok := profiles[name]; ok {
+		profileCopy := *profile
+		return &profileCopy, nil
+	}
+	return nil, fmt.Errorf("unknown key profile %q", name)
+}
+
+// KeyProfileNames Names of all registered profiles, sorted
+func KeyProfileNames() (names []string) {
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LoadKeyProfiles Reads and registers a JSON array of KeyProfile
+func LoadKeyProfiles(r io.Reader) error {
+	var list []KeyProfile
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return err
+	}
+
+	// Validate all first, so a bad file registers nothing
+	for _, profile := range list {
+		profile.fillDefaults()
+		if err := profile.validateRegistration(); err != nil {
+			return err
+		}
+	}
+	for _, profile := range list {
+		if err := RegisterKeyProfile(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadKeyProfilesFile As LoadKeyProfiles, reading from the file at path
+func LoadKeyProfilesFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadKeyProfiles(f)
+}
+
+// Validate Checks that the key table is complete, without zero keys, and that offsets and CRC variant exist.
+// The alternate key table is not checked, as AlternateMangleTable itself is not known
+func (p *KeyProfile) Validate() error {
+	if p.Name == "" {
+		return errors.New("key profile without name")
+	}
+	for i, key := range p.KeyTable {
+		if key == (MangleKeyData{}) {
+			return fmt.Errorf("key profile %q: missing or zero key_table entry %d", p.Name, i)
+		}
+	}
+	for _, offset := range []OuterMangleKeyOffset{p.OuterKeyOffsetFlash, p.OuterKeyOffsetMemory, p.OuterKeyOffsetDeviceId, p.OuterKeyOffsetDeviceCode} {
+		if offset < 0 || int(offset) >= len(p.KeyTable) {
+			return fmt.Errorf("key profile %q: invalid outer key offset %d", p.Name, offset)
+		}
+	}
+	if _, err := p.crcFunc(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateRegistration As Validate, also rejecting DefaultKeyProfileName, which stays the fallback of material without profile
+func (p *KeyProfile) validateRegistration() error {
+	if p.Name == DefaultKeyProfileName {
+		return fmt.Errorf("key profile %q is reserved", p.Name)
+	}
+	return p.Validate()
+}
+
+// fillDefaults Fills an omitted AlternateKeyTable from AlternateMangleTable
+func (p *KeyProfile) fillDefaults() {
+	if p.AlternateKeyTable == (MangleKeyTable{}) {
+		p.AlternateKeyTable = AlternateMangleTable
+	}
+}
+
+func (p *KeyProfile) crcFunc() (func(data []byte) uint32, error) {
+	name := p.CRC
+	if name == "" {
+		name = CRCVariantDefault
+	}
+
+	profilesLock.RLock()
+	defer profilesLock.RUnlock()
+	fn, ok := crcVariants[name]
+	if !ok {
+		return nil, fmt.Errorf("key profile %q: unknown CRC variant %q", p.Name, name)
+	}
+	return fn, nil
+}
+
+// CalculateCRC CRC of data using the profile CRC variant
+func (p *KeyProfile) CalculateCRC(data []byte) (uint32, error) {
+	material, err := p.FlashKeyMaterial(nil)
+	if err != nil {
+		return 0, err
+	}
+	return material.CalculateCRC(data), nil
+}
+
+// KeyMaterial Material using the profile tables and CRC variant, with outer key offset.
+// Errors if the CRC variant is not registered
+func (p *KeyProfile) KeyMaterial(generator KeyGenerator, offset OuterMangleKeyOffset) (KeyMaterial, error) {
+	fn, err := p.crcFunc()
+	if err != nil {
+		return KeyMaterial{}, err
+	}
+	return KeyMaterial{
+		Generator:         generator,
+		OuterKeyOffset:    offset,
+		KeyTable:          &p.KeyTable,
+		AlternateKeyTable: &p.AlternateKeyTable,
+		CRC:               fn,
+	}, nil
+}
+
+// FlashKeyMaterial As NewFlashKeyMaterial, using the profile
+func (p *KeyProfile) FlashKeyMaterial(generator KeyGenerator) (KeyMaterial, error) {
+	return p.KeyMaterial(generator, p.OuterKeyOffsetFlash)
+}
+
+// MemoryKeyMaterial As NewMemoryKeyMaterial, using the profile
+func (p *KeyProfile) MemoryKeyMaterial(generator KeyGenerator) (KeyMaterial, error) {
+	return p.KeyMaterial(generator, p.OuterKeyOffsetMemory)
+}
+
+// DeviceFlashKeyMaterial As NewDeviceFlashKeyMaterial, using the profile
+func (p *KeyProfile) DeviceFlashKeyMaterial(generator KeyGenerator, deviceId DeviceId) (KeyMaterial, error) {
+	if generator != nil {
+		generator = NewMangleIndexGeneratorWrapper(generator, MangleIndexDeviceKey)
+	}
+	material, err := p.KeyMaterial(generator, p.OuterKeyOffsetFlash)
+	if err != nil {
+		return KeyMaterial{}, err
+	}
+	material.DeviceKey = p.DeviceMangleKeyOffset6(deviceId)
+	return material, nil
+}
+
+// DeviceMangleKeyOffset6 As DeviceMangleKeyOffset6, using the profile
+func (p *KeyProfile) DeviceMangleKeyOffset6(deviceId DeviceId) *MangleKeyData {
+	return deviceMangleKeyOffset6(p.KeyTable[p.OuterKeyOffsetDeviceId], deviceId)
+}
+
+// DeviceMangleKeyOffset0 As DeviceMangleKeyOffset0, using the profile
+func (p *KeyProfile) DeviceMangleKeyOffset0(deviceId DeviceId) *MangleKeyData {
+	return deviceMangleKeyOffset0(p.KeyTable[p.OuterKeyOffsetDeviceCode], deviceId)
+}
+
+// DecryptDeviceCode As DecryptDeviceCode, using the profile
+func (p *KeyProfile) DecryptDeviceCode(deviceId DeviceId, code []byte) []byte {
+	return decryptDeviceCode(p.DeviceMangleKeyOffset0(deviceId), code)
+}
+
+// EncryptDeviceCode As EncryptDeviceCode, using the profile
+func (p *KeyProfile) EncryptDeviceCode(deviceId DeviceId, code []byte) []byte {
+	return encryptDeviceCode(p.DeviceMangleKeyOffset0(deviceId), code)
+}
+
+// DeviceIdFromMangleKeyOffset0 As DeviceIdFromMangleKeyOffset0, using the profile
+func (p *KeyProfile) DeviceIdFromMangleKeyOffset0(key MangleKeyData) (deviceId DeviceId, ok bool) {
+	return deviceIdFromMangleKeyOffset0(p.KeyTable[p.OuterKeyOffsetDeviceCode], key)
+}
+
+// ScanBlocks As ScanBlocks, using memory and flash material of the profile
+func (p *KeyProfile) ScanBlocks(dump []byte, maxDataSize int) ([]ScanResult, error) {
+	memory, err := p.MemoryKeyMaterial(nil)
+	if err != nil {
+		return nil, err
+	}
+	flash, err := p.FlashKeyMaterial(nil)
+	if err != nil {
+		return nil, err
+	}
+	return ScanBlocks(dump, maxDataSize, memory, flash), nil
+}
+
+// MarshalText Encodes key as hex, as used in KeyProfile files
+func (d MangleKeyData) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(d[:])), nil
+}
+
+func (d *MangleKeyData) UnmarshalText(text []byte) error {
+	buf, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	if len(buf) != len(d) {
+		return fmt.Errorf("key must be %d bytes, got %d", len(d), len(buf))
+	}
+	copy(d[:], buf)
+	return nil
+}
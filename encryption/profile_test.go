@@ -0,0 +1,205 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestKeyProfile_Default(t *testing.T) {
+	t.Parallel()
+
+	profile, err := GetKeyProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	generator := BorlandRandKeyGenerator(1)
+	a := NewEncryptedBlock(len(data))
+	copy(a.DataBlock(), data)
+	if err = a.Encrypt(NewFlashKeyMaterial(&generator)); err != nil {
+		t.Fatal(err)
+	}
+
+	generator = BorlandRandKeyGenerator(1)
+	b := NewEncryptedBlock(len(data))
+	copy(b.DataBlock(), data)
+	material, err := profile.FlashKeyMaterial(&generator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(a, b) {
+		t.Fatal("default profile does not match hardcoded material")
+	}
+
+	deviceId := DeviceId{0x11223344, 0x55667788, 0x99aabbcc}
+	if *profile.DeviceMangleKeyOffset6(deviceId) != *DeviceMangleKeyOffset6(deviceId) {
+		t.Fatal("device key mismatch")
+	}
+	if *profile.DeviceMangleKeyOffset0(deviceId) != *DeviceMangleKeyOffset0(deviceId) {
+		t.Fatal("device code key mismatch")
+	}
+	if !slices.Equal(profile.EncryptDeviceCode(deviceId, slices.Clone(data)), EncryptDeviceCode(deviceId, slices.Clone(data))) {
+		t.Fatal("device code mismatch")
+	}
+	if id, ok := profile.DeviceIdFromMangleKeyOffset0(*DeviceMangleKeyOffset0(deviceId)); !ok || id != deviceId {
+		t.Fatal("device id mismatch")
+	}
+}
+
+func TestLoadKeyProfiles(t *testing.T) {
+	t.Parallel()
+
+	profile := *DefaultKeyProfile()
+	profile.Name = "test-swapped"
+	profile.KeyTable[2], profile.KeyTable[3] = profile.KeyTable[3], profile.KeyTable[2]
+	profile.OuterKeyOffsetFlash = 2
+
+	buf, err := json.Marshal([]KeyProfile{profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = LoadKeyProfiles(bytes.NewReader(buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := GetKeyProfile(profile.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loaded != profile {
+		t.Fatal("loaded profile mismatch")
+	}
+	if !slices.Contains(KeyProfileNames(), profile.Name) {
+		t.Fatal("profile not listed")
+	}
+
+	data := make(EncryptedBlock, EncryptedBlockKeySize+64)
+	generator := BorlandRandKeyGenerator(2)
+	material, err := loaded.FlashKeyMaterial(&generator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = data.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+	material.Generator = nil
+	if err = slices.Clone(data).Decrypt(material, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = slices.Clone(data).Decrypt(NewFlashKeyMaterial(nil), true); err == nil {
+		t.Fatal("decrypted with default material")
+	}
+
+	if results, err := loaded.ScanBlocks(data, 64); err != nil || len(results) != 1 || results[0].Material.OuterKeyOffset != 2 {
+		t.Fatal("block not found with profile")
+	}
+	if results := ScanBlocks(data, 64); len(results) != 0 {
+		t.Fatal("block found with default material")
+	}
+
+	deviceId := DeviceId{0x11223344, 0x55667788, 0x99aabbcc}
+	code := loaded.EncryptDeviceCode(deviceId, make([]byte, 16))
+	if !slices.Equal(loaded.DecryptDeviceCode(deviceId, slices.Clone(code)), make([]byte, 16)) {
+		t.Fatal("device code mismatch")
+	}
+}
+
+func TestLoadKeyProfiles_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, text := range []string{
+		`[{"name": ""}]`,
+		`[{"name": "default", "key_table": ["6fc039050158233a80dab41b656a9144"]}]`,
+		`[{"name": "test-invalid", "outer_key_offset_flash": 8}]`,
+		`[{"name": "test-invalid", "crc": "unknown"}]`,
+		`[{"name": "test-invalid", "key_table": ["00"]}]`,
+		// Missing, short and zero key tables
+		`[{"name": "test-invalid"}]`,
+		`[{"name": "test-invalid", "key_table": ["6fc039050158233a80dab41b656a9144"]}]`,
+		`[{"name": "test-invalid", "key_table": ["00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000", "00000000000000000000000000000000"]}]`,
+	} {
+		if err := LoadKeyProfiles(bytes.NewReader([]byte(text))); err == nil {
+			t.Fatalf("expected error loading %s", text)
+		}
+	}
+
+	if _, err := GetKeyProfile("test-invalid"); err == nil {
+		t.Fatal("invalid profile registered")
+	}
+
+	profile := *DefaultKeyProfile()
+	profile.KeyTable[0], profile.KeyTable[1] = profile.KeyTable[1], profile.KeyTable[0]
+	if err := RegisterKeyProfile(profile); err == nil {
+		t.Fatal("default profile replaced")
+	}
+}
+
+func TestGetKeyProfile_Copy(t *testing.T) {
+	t.Parallel()
+
+	profile, err := GetKeyProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.KeyTable[0] = MangleKeyData{}
+	profile.OuterKeyOffsetFlash = 3
+
+	if profile, err = GetKeyProfile(""); err != nil {
+		t.Fatal(err)
+	}
+	if *profile != *DefaultKeyProfile() || profile.KeyTable != HardcodedMangleTable {
+		t.Fatal("default profile modified")
+	}
+}
+
+func TestLoadKeyProfiles_DefaultAlternateKeyTable(t *testing.T) {
+	t.Parallel()
+
+	profile := *DefaultKeyProfile()
+	profile.Name = "test-no-alternate"
+	profile.KeyTable[4], profile.KeyTable[5] = profile.KeyTable[5], profile.KeyTable[4]
+
+	// Omitted alternate_key_table
+	buf, err := json.Marshal([]map[string]any{{
+		"name":      profile.Name,
+		"key_table": profile.KeyTable,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = LoadKeyProfiles(bytes.NewReader(buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := GetKeyProfile(profile.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.KeyTable != profile.KeyTable || loaded.AlternateKeyTable != AlternateMangleTable {
+		t.Fatal("loaded profile mismatch")
+	}
+}
+
+func TestKeyProfile_UnknownCRC(t *testing.T) {
+	t.Parallel()
+
+	profile := DefaultKeyProfile()
+	profile.CRC = "test-unknown"
+	if _, err := profile.FlashKeyMaterial(nil); err == nil {
+		t.Fatal("expected error on unknown CRC variant")
+	}
+	if _, err := profile.ScanBlocks(nil, 64); err == nil {
+		t.Fatal("expected error on unknown CRC variant")
+	}
+}
@@ -0,0 +1,119 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"slices"
+)
+
+// ScanResult Encrypted block found within a dump
+type ScanResult struct {
+	// Offset Start of the key block within the dump
+	Offset int
+	// Size Data size, excluding key block. For compressed blocks, size of compressed data aligned to MangleKeyBlockSize
+	Size     int
+	Material KeyMaterial
+	// Block Decrypted block
+	Block EncryptedBlock
+	// Decompressed Original data of compressed blocks, over which the block CRC is calculated. nil if not compressed
+	Decompressed []byte
+}
+
+// ScanBlocks Tests each 8-aligned offset of dump as the start of an encrypted block for each material, default memory and flash if none are given.
+// See KeyProfile.ScanBlocks for other profiles.
+// The CRC pair is used as a validity check, then data length is found by matching the CRC over growing lengths, up to maxDataSize.
+// If no length matches, data is decompressed for growing lengths instead, as the CRC of compressed blocks covers uncompressed data.
+// Dump after a found block is skipped
+func ScanBlocks(dump []byte, maxDataSize int, materials ...KeyMaterial) (results []ScanResult) {
+	if len(materials) == 0 {
+		materials = []KeyMaterial{NewMemoryKeyMaterial(nil), NewFlashKeyMaterial(nil)}
+	}
+
+	keyBlock := make(EncryptedBlock, EncryptedBlockKeySize)
+
+	for offset := 0; offset+EncryptedBlockKeySize <= len(dump); {
+		var found bool
+		for _, material := range materials {
+			copy(keyBlock, dump[offset:])
+			size, decompressed, ok := scanBlock(keyBlock, dump[offset+EncryptedBlockKeySize:min(len(dump), offset+EncryptedBlockKeySize+maxDataSize)], material)
+			if !ok {
+				continue
+			}
+
+			b := slices.Clone(EncryptedBlock(dump[offset : offset+EncryptedBlockKeySize+size]))
+			if err := b.Decrypt(material, decompressed == nil); err != nil {
+				continue
+			}
+
+			results = append(results, ScanResult{
+				Offset:       offset,
+				Size:         size,
+				Material:     material,
+				Block:        b,
+				Decompressed: decompressed,
+			})
+			offset += len(b)
+			found = true
+			break
+		}
+
+		if !found {
+			offset += MangleKeyBlockSize
+		}
+	}
+
+	return results
+}
+
+// scanBlock Decrypts keyBlock in place, and returns data size when its CRC matches, with decompressed data if compressed
+func scanBlock(keyBlock EncryptedBlock, data []byte, material KeyMaterial) (size int, decompressed []byte, ok bool) {
+	material.OuterKey().Decrypt(keyBlock)
+
+	keyData, err := mangleKeyData(material, keyBlock.MangleIndex())
+	if err != nil {
+		return 0, nil, false
+	}
+
+	// Check CRC pair first, only one Mangle block
+	crcPair := keyData.DecryptBlock(binary.LittleEndian.Uint64(keyBlock[EncryptedBlockCRC1Offset:]))
+	crc1 := uint32(crcPair)
+	if crc1 != uint32(crcPair>>32) {
+		return 0, nil, false
+	}
+
+	keyData.Decrypt(keyBlock.MangleKeyBlock())
+	schedule := keyBlock.MangleKey().Schedule()
+
+	// Only reached on a valid CRC pair, so decrypting all data at once is rare
+	decrypted := slices.Clone(data[:len(data)-len(data)%MangleKeyBlockSize])
+	schedule.Decrypt(decrypted)
+
+	if size, ok = scanDataSize(decrypted, crc1, material); ok {
+		return size, nil, true
+	}
+
+	return findCompressedSize(decrypted, crc1, material)
+}
+
+// scanDataSize Finds the length of decrypted whose CRC matches crc1
+func scanDataSize(decrypted []byte, crc1 uint32, material KeyMaterial) (size int, ok bool) {
+	if material.CRC != nil {
+		for size = MangleKeyBlockSize; size <= len(decrypted); size += MangleKeyBlockSize {
+			if material.CRC(decrypted[:size]) == crc1 {
+				return size, true
+			}
+		}
+		return 0, false
+	}
+
+	// Default CRC can be calculated incrementally, as blocks are multiple of its chunk size
+	c := crc.NewCRC()
+	for size = MangleKeyBlockSize; size <= len(decrypted); size += MangleKeyBlockSize {
+		c.Update(decrypted[size-MangleKeyBlockSize : size])
+		if c.Sum32() == crc1 {
+			return size, true
+		}
+	}
+
+	return 0, false
+}
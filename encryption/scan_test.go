@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"io"
+	"testing"
+)
+
+func TestScanBlocks(t *testing.T) {
+	t.Parallel()
+
+	dump := make([]byte, 0x4000)
+	if _, err := io.ReadFull(rand.Reader, dump); err != nil {
+		t.Fatal(err)
+	}
+
+	type expected struct {
+		Offset         int
+		Size           int
+		OuterKeyOffset OuterMangleKeyOffset
+		Data           []byte
+	}
+	var blocks []expected
+
+	for _, e := range []struct {
+		Offset   int
+		Size     int
+		Material KeyMaterial
+	}{
+		{0x100, 0x40, NewMemoryKeyMaterial(&SecureRandomKeyGenerator{})},
+		{0x1008, 0x200, NewFlashKeyMaterial(&SecureRandomKeyGenerator{})},
+		{0x3000, 0x8, NewMemoryKeyMaterial(&SecureRandomKeyGenerator{})},
+	} {
+		b := NewEncryptedBlock(e.Size)
+		if _, err := io.ReadFull(rand.Reader, b.DataBlock()); err != nil {
+			t.Fatal(err)
+		}
+		data := bytes.Clone(b.DataBlock())
+		if err := b.Encrypt(e.Material); err != nil {
+			t.Fatal(err)
+		}
+		copy(dump[e.Offset:], b)
+
+		blocks = append(blocks, expected{e.Offset, e.Size, e.Material.OuterKeyOffset, data})
+	}
+
+	results := ScanBlocks(dump, 0x1000)
+	if len(results) != len(blocks) {
+		t.Fatalf("expected %d results, got %d", len(blocks), len(results))
+	}
+
+	for i, r := range results {
+		e := blocks[i]
+		if r.Offset != e.Offset || r.Size != e.Size || r.Material.OuterKeyOffset != e.OuterKeyOffset {
+			t.Fatalf("unexpected result at %d: offset %x, size %x, outer key offset %d", i, r.Offset, r.Size, r.Material.OuterKeyOffset)
+		}
+		if !bytes.Equal(r.Block.DataBlock(), e.Data) {
+			t.Fatalf("data mismatch at %d", i)
+		}
+	}
+}
+
+func TestScanBlocks_Compressed(t *testing.T) {
+	t.Parallel()
+
+	dump := make([]byte, 0x2000)
+	if _, err := io.ReadFull(rand.Reader, dump); err != nil {
+		t.Fatal(err)
+	}
+
+	// Partially random, so compressed size is not aligned
+	data := make([]byte, 0x800)
+	if _, err := io.ReadFull(rand.Reader, data[:0x123]); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := compression.FirmwareBlockCompress(data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewEncryptedBlock(int(PaddedSize(int64(len(payload)))))
+	copy(b.DataBlock(), payload)
+	material := NewFlashKeyMaterial(&SecureRandomKeyGenerator{})
+	material.CRC = func([]byte) uint32 {
+		return crc.CalculateCRC(data)
+	}
+	if err = b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+	copy(dump[0x408:], b)
+
+	results := ScanBlocks(dump, 0x1000)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if r := results[0]; r.Offset != 0x408 || r.Size != len(b.DataBlock()) || !bytes.Equal(r.Decompressed, data) {
+		t.Fatalf("unexpected result: offset %x, size %x, decompressed %d bytes", r.Offset, r.Size, len(r.Decompressed))
+	}
+}
@@ -0,0 +1,154 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync"
+)
+
+// MangleKeySchedule Precomputed round keys of MangleKeyData, with round constants added
+type MangleKeySchedule [MangleKeyRounds]uint32
+
+// scheduleInterleave Blocks processed per loop iteration
+const scheduleInterleave = 4
+
+// scheduleParallelMinSize Smallest amount of data given to a worker by EncryptParallel and DecryptParallel
+const scheduleParallelMinSize = 64 * 1024
+
+func (d MangleKeyData) Schedule() (s MangleKeySchedule) {
+	for round := range s {
+		s[round] = d.RoundKey(round) + uint32(round)
+	}
+	return s
+}
+
+func (s *MangleKeySchedule) EncryptBlock(block uint64) uint64 {
+	dataA, dataB := uint32(block), uint32(block>>32)
+
+	for _, k := range s {
+		dataA, dataB = dataB, k+((dataB>>8)^(dataB<<6))+dataB+dataA
+	}
+
+	return uint64(dataA) | (uint64(dataB) << 32)
+}
+
+func (s *MangleKeySchedule) DecryptBlock(block uint64) uint64 {
+	dataA, dataB := uint32(block), uint32(block>>32)
+
+	for round := MangleKeyRounds - 1; round >= 0; round-- {
+		dataA, dataB = dataB-dataA-((dataA>>8)^(dataA<<6))-s[round], dataA
+	}
+
+	return uint64(dataA) | (uint64(dataB) << 32)
+}
+
+// Encrypt Processes several blocks per loop iteration, so their rounds can execute in parallel
+func (s *MangleKeySchedule) Encrypt(data []byte) {
+	if len(data)%MangleKeyBlockSize != 0 {
+		panic("len must be % 8")
+	}
+
+	const stride = MangleKeyBlockSize * scheduleInterleave
+	i := 0
+	for ; i+stride <= len(data); i += stride {
+		b := data[i : i+stride]
+		a0, b0 := binary.LittleEndian.Uint32(b[0:]), binary.LittleEndian.Uint32(b[4:])
+		a1, b1 := binary.LittleEndian.Uint32(b[8:]), binary.LittleEndian.Uint32(b[12:])
+		a2, b2 := binary.LittleEndian.Uint32(b[16:]), binary.LittleEndian.Uint32(b[20:])
+		a3, b3 := binary.LittleEndian.Uint32(b[24:]), binary.LittleEndian.Uint32(b[28:])
+
+		for _, k := range s {
+			a0, b0 = b0, k+((b0>>8)^(b0<<6))+b0+a0
+			a1, b1 = b1, k+((b1>>8)^(b1<<6))+b1+a1
+			a2, b2 = b2, k+((b2>>8)^(b2<<6))+b2+a2
+			a3, b3 = b3, k+((b3>>8)^(b3<<6))+b3+a3
+		}
+
+		binary.LittleEndian.PutUint32(b[0:], a0)
+		binary.LittleEndian.PutUint32(b[4:], b0)
+		binary.LittleEndian.PutUint32(b[8:], a1)
+		binary.LittleEndian.PutUint32(b[12:], b1)
+		binary.LittleEndian.PutUint32(b[16:], a2)
+		binary.LittleEndian.PutUint32(b[20:], b2)
+		binary.LittleEndian.PutUint32(b[24:], a3)
+		binary.LittleEndian.PutUint32(b[28:], b3)
+	}
+
+	for ; i < len(data); i += MangleKeyBlockSize {
+		binary.LittleEndian.PutUint64(data[i:], s.EncryptBlock(binary.LittleEndian.Uint64(data[i:])))
+	}
+}
+
+// Decrypt Processes several blocks per loop iteration, so their rounds can execute in parallel
+func (s *MangleKeySchedule) Decrypt(data []byte) {
+	if len(data)%MangleKeyBlockSize != 0 {
+		panic("len must be % 8")
+	}
+
+	const stride = MangleKeyBlockSize * scheduleInterleave
+	i := 0
+	for ; i+stride <= len(data); i += stride {
+		b := data[i : i+stride]
+		a0, b0 := binary.LittleEndian.Uint32(b[0:]), binary.LittleEndian.Uint32(b[4:])
+		a1, b1 := binary.LittleEndian.Uint32(b[8:]), binary.LittleEndian.Uint32(b[12:])
+		a2, b2 := binary.LittleEndian.Uint32(b[16:]), binary.LittleEndian.Uint32(b[20:])
+		a3, b3 := binary.LittleEndian.Uint32(b[24:]), binary.LittleEndian.Uint32(b[28:])
+
+		for round := MangleKeyRounds - 1; round >= 0; round-- {
+			k := s[round]
+			a0, b0 = b0-a0-((a0>>8)^(a0<<6))-k, a0
+			a1, b1 = b1-a1-((a1>>8)^(a1<<6))-k, a1
+			a2, b2 = b2-a2-((a2>>8)^(a2<<6))-k, a2
+			a3, b3 = b3-a3-((a3>>8)^(a3<<6))-k, a3
+		}
+
+		binary.LittleEndian.PutUint32(b[0:], a0)
+		binary.LittleEndian.PutUint32(b[4:], b0)
+		binary.LittleEndian.PutUint32(b[8:], a1)
+		binary.LittleEndian.PutUint32(b[12:], b1)
+		binary.LittleEndian.PutUint32(b[16:], a2)
+		binary.LittleEndian.PutUint32(b[20:], b2)
+		binary.LittleEndian.PutUint32(b[24:], a3)
+		binary.LittleEndian.PutUint32(b[28:], b3)
+	}
+
+	for ; i < len(data); i += MangleKeyBlockSize {
+		binary.LittleEndian.PutUint64(data[i:], s.DecryptBlock(binary.LittleEndian.Uint64(data[i:])))
+	}
+}
+
+// EncryptParallel As Encrypt, with data split across workers. If workers is zero or less, runtime.NumCPU() is used
+func (s *MangleKeySchedule) EncryptParallel(data []byte, workers int) {
+	s.parallel(data, workers, s.Encrypt)
+}
+
+// DecryptParallel As Decrypt, with data split across workers. If workers is zero or less, runtime.NumCPU() is used
+func (s *MangleKeySchedule) DecryptParallel(data []byte, workers int) {
+	s.parallel(data, workers, s.Decrypt)
+}
+
+func (s *MangleKeySchedule) parallel(data []byte, workers int, fn func(data []byte)) {
+	if len(data)%MangleKeyBlockSize != 0 {
+		panic("len must be % 8")
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	chunkSize := max(scheduleParallelMinSize, len(data)/workers)
+	chunkSize -= chunkSize % MangleKeyBlockSize
+	if chunkSize >= len(data) {
+		fn(data)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < len(data); i += chunkSize {
+		wg.Add(1)
+		go func(chunk []byte) {
+			defer wg.Done()
+			fn(chunk)
+		}(data[i:min(len(data), i+chunkSize)])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,51 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestMangleKeySchedule(t *testing.T) {
+	t.Parallel()
+
+	var key MangleKeyData
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		t.Fatal(err)
+	}
+	schedule := key.Schedule()
+
+	for _, size := range []int{8, 24, 32, 40, 1024, 8 * 31, scheduleParallelMinSize*3 + 8*5} {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, data); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := bytes.Clone(data)
+		key.Encrypt(expected)
+
+		for name, encrypt := range map[string]func([]byte){
+			"Encrypt":         schedule.Encrypt,
+			"EncryptParallel": func(data []byte) { schedule.EncryptParallel(data, 4) },
+		} {
+			buf := bytes.Clone(data)
+			encrypt(buf)
+			if !bytes.Equal(buf, expected) {
+				t.Fatalf("%s mismatch on size %d", name, size)
+			}
+		}
+
+		buf := bytes.Clone(expected)
+		schedule.Decrypt(buf)
+		if !bytes.Equal(buf, data) {
+			t.Fatalf("Decrypt mismatch on size %d", size)
+		}
+
+		buf = bytes.Clone(expected)
+		schedule.DecryptParallel(buf, 4)
+		if !bytes.Equal(buf, data) {
+			t.Fatalf("DecryptParallel mismatch on size %d", size)
+		}
+	}
+}
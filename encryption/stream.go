@@ -0,0 +1,247 @@
+package encryption
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"io"
+)
+
+// streamChunkSize Data processed at a time by BlockWriter and BlockReader
+const streamChunkSize = 32 * 1024
+
+// PaddedSize Size of data block holding size bytes
+func PaddedSize(size int64) int64 {
+	if size%MangleKeyBlockSize != 0 {
+		size += MangleKeyBlockSize - size%MangleKeyBlockSize
+	}
+	return size
+}
+
+// StreamTrailerSize Size of the encrypted trailer following data written by BlockWriter
+const StreamTrailerSize = MangleKeyBlockSize * 2
+
+// BlockWriter Encrypts a stream of arbitrary length into the EncryptedBlock format, zero padded to MangleKeyBlockSize.
+// The data CRC is not known when the key block is written, so its CRC fields are left zero, and a trailer follows data instead:
+// the true length as uint64 and the CRC pair, encrypted with the data key. As such the stream can be written to any io.Writer,
+// but is only readable by BlockReader, not EncryptedBlock.Decrypt
+type BlockWriter struct {
+	w        io.Writer
+	schedule MangleKeySchedule
+	crc      crc.CRC
+
+	buf    []byte
+	length int64
+	err    error
+}
+
+// NewBlockWriter Generates the key block from material, and writes it to w
+func NewBlockWriter(w io.Writer, material KeyMaterial) (*BlockWriter, error) {
+	if material.CRC != nil {
+		return nil, errors.New("custom CRC not supported when streaming")
+	}
+
+	keyBlock := make(EncryptedBlock, EncryptedBlockKeySize)
+
+	// Same generator order as EncryptedBlock.Encrypt
+	material.Generator.FillKeyBlock(keyBlock[EncryptedBlockMangleKeyOffset:EncryptedBlockCRC1Offset])
+	material.Generator.FillKeyBlock(keyBlock[EncryptedBlockPaddingKeyOffset:EncryptedBlockKeySize])
+	mangleIndex := material.Generator.MangleIndex()
+
+	bw := &BlockWriter{
+		w:        w,
+		schedule: keyBlock.MangleKey().Schedule(),
+		crc:      crc.NewCRC(),
+		buf:      make([]byte, 0, streamChunkSize),
+	}
+
+	if err := keyBlock.encryptKeyBlock(material, mangleIndex); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(keyBlock); err != nil {
+		return nil, err
+	}
+
+	return bw, nil
+}
+
+// Length Bytes written so far, excluding padding
+func (bw *BlockWriter) Length() int64 {
+	return bw.length
+}
+
+func (bw *BlockWriter) Write(p []byte) (n int, err error) {
+	if bw.err != nil {
+		return 0, bw.err
+	}
+
+	for len(p) > 0 {
+		written := copy(bw.buf[len(bw.buf):cap(bw.buf)], p)
+		bw.buf = bw.buf[:len(bw.buf)+written]
+		p = p[written:]
+		n += written
+		bw.length += int64(written)
+
+		if len(bw.buf) == cap(bw.buf) {
+			if err = bw.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// flush Encrypts and writes all buffered full Mangle blocks
+func (bw *BlockWriter) flush() error {
+	size := len(bw.buf) - len(bw.buf)%MangleKeyBlockSize
+	chunk := bw.buf[:size]
+
+	bw.crc.Update(chunk)
+	bw.schedule.Encrypt(chunk)
+	if _, err := bw.w.Write(chunk); err != nil {
+		bw.err = err
+		return err
+	}
+
+	bw.buf = bw.buf[:copy(bw.buf, bw.buf[size:])]
+	return nil
+}
+
+// Close Pads and writes remaining data, then writes the trailer. The underlying writer is not closed
+func (bw *BlockWriter) Close() error {
+	if bw.err != nil {
+		return bw.err
+	}
+	bw.err = errors.New("writer closed")
+
+	// Zero padding is included in CRC, as done by EncryptedBlock.Encrypt
+	for len(bw.buf)%MangleKeyBlockSize != 0 {
+		bw.buf = append(bw.buf, 0)
+	}
+	if err := bw.flush(); err != nil {
+		return err
+	}
+
+	crcValue := bw.crc.Sum32()
+	trailer := make([]byte, StreamTrailerSize)
+	binary.LittleEndian.PutUint64(trailer, uint64(bw.length))
+	binary.LittleEndian.PutUint32(trailer[MangleKeyBlockSize:], crcValue)
+	binary.LittleEndian.PutUint32(trailer[MangleKeyBlockSize+4:], crcValue)
+	bw.schedule.Encrypt(trailer)
+
+	_, err := bw.w.Write(trailer)
+	return err
+}
+
+// streamHoldback Bytes kept back by BlockReader until EOF, the trailer and the last data block, which may hold padding
+const streamHoldback = StreamTrailerSize + MangleKeyBlockSize
+
+// BlockReader Decrypts a stream written by BlockWriter, returning data up to the length recorded in the trailer
+// and verifying the data CRC at its end
+type BlockReader struct {
+	r        io.Reader
+	schedule MangleKeySchedule
+
+	// returned Data bytes returned so far, including padding
+	returned  int64
+	verifyCrc bool
+	crc       crc.CRC
+
+	chunk   []byte
+	held    int
+	pending []byte
+	err     error
+}
+
+// NewBlockReader Reads and decrypts the key block from r
+func NewBlockReader(r io.Reader, material KeyMaterial, verifyCrc bool) (*BlockReader, error) {
+	if verifyCrc && material.CRC != nil {
+		return nil, errors.New("custom CRC not supported when streaming")
+	}
+
+	keyBlock := make(EncryptedBlock, EncryptedBlockKeySize)
+	if _, err := io.ReadFull(r, keyBlock); err != nil {
+		return nil, err
+	}
+	if err := keyBlock.decryptKeyBlock(material); err != nil {
+		return nil, err
+	}
+
+	return &BlockReader{
+		r:         r,
+		schedule:  keyBlock.MangleKey().Schedule(),
+		verifyCrc: verifyCrc,
+		crc:       crc.NewCRC(),
+		chunk:     make([]byte, streamHoldback+streamChunkSize),
+	}, nil
+}
+
+func (br *BlockReader) Read(p []byte) (n int, err error) {
+	for len(br.pending) == 0 {
+		if br.err != nil {
+			return 0, br.err
+		}
+		br.err = br.fill()
+	}
+
+	n = copy(p, br.pending)
+	br.pending = br.pending[n:]
+	return n, nil
+}
+
+// fill Reads and decrypts the next chunk into pending, keeping back streamHoldback bytes until EOF
+func (br *BlockReader) fill() error {
+	// Held bytes were left at the end of the previous chunk
+	copy(br.chunk, br.chunk[len(br.chunk)-br.held:])
+
+	n, err := io.ReadFull(br.r, br.chunk[br.held:])
+	n += br.held
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return br.finish(br.chunk[:n])
+	} else if err != nil {
+		return err
+	}
+
+	chunk := br.chunk[:n-streamHoldback]
+	br.held = streamHoldback
+	br.schedule.Decrypt(chunk)
+	br.crc.Update(chunk)
+	br.returned += int64(len(chunk))
+	br.pending = chunk
+	return nil
+}
+
+// finish Decrypts the last data block and trailer in chunk, and sets pending to the remaining data up to recorded length
+func (br *BlockReader) finish(chunk []byte) error {
+	if len(chunk) < StreamTrailerSize {
+		return io.ErrUnexpectedEOF
+	} else if len(chunk)%MangleKeyBlockSize != 0 {
+		return errors.New("data not % 8")
+	}
+	br.schedule.Decrypt(chunk)
+
+	data, trailer := chunk[:len(chunk)-StreamTrailerSize], chunk[len(chunk)-StreamTrailerSize:]
+	br.crc.Update(data)
+
+	length := binary.LittleEndian.Uint64(trailer)
+	crc1, crc2 := binary.LittleEndian.Uint32(trailer[MangleKeyBlockSize:]), binary.LittleEndian.Uint32(trailer[MangleKeyBlockSize+4:])
+	if crc1 != crc2 {
+		return errors.New("invalid CRC pair")
+	}
+
+	padded := br.returned + int64(len(data))
+	if length > uint64(padded) || PaddedSize(int64(length)) != padded {
+		return fmt.Errorf("recorded length %d not matching data size %d", length, padded)
+	}
+
+	if br.verifyCrc {
+		if calculatedCrc := br.crc.Sum32(); calculatedCrc != crc1 {
+			return fmt.Errorf("data CRC not matching: expected %08x, got %08x", crc1, calculatedCrc)
+		}
+	}
+
+	br.pending = data[:int64(length)-br.returned]
+	return io.EOF
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestBlockWriter_BlockReader(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 5, 8, 1000, streamChunkSize - 3, streamChunkSize + 3, streamChunkSize * 3} {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, data); err != nil {
+			t.Fatal(err)
+		}
+
+		material := NewMemoryKeyMaterial(&SecureRandomKeyGenerator{})
+
+		// Neither end can seek
+		pr, pw := io.Pipe()
+		go func() {
+			w, err := NewBlockWriter(pw, material)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			// Uneven writes
+			for i := 0; i < len(data); i += 777 {
+				if _, err = w.Write(data[i:min(len(data), i+777)]); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			if w.Length() != int64(size) {
+				pw.CloseWithError(fmt.Errorf("unexpected length %d", w.Length()))
+				return
+			}
+			pw.CloseWithError(w.Close())
+		}()
+
+		var encrypted bytes.Buffer
+		r, err := NewBlockReader(io.TeeReader(pr, &encrypted), material, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Fatalf("stream data mismatch on size %d", size)
+		}
+		if encrypted.Len() != EncryptedBlockKeySize+int(PaddedSize(int64(size)))+StreamTrailerSize {
+			t.Fatalf("unexpected encrypted size %d", encrypted.Len())
+		}
+
+		// Recorded length must match data
+		truncated := encrypted.Bytes()[:encrypted.Len()-StreamTrailerSize-MangleKeyBlockSize]
+		truncated = append(slices.Clone(truncated), encrypted.Bytes()[encrypted.Len()-StreamTrailerSize:]...)
+		if r, err = NewBlockReader(bytes.NewReader(truncated), material, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.ReadAll(r); err == nil {
+			t.Fatalf("expected error with truncated data on size %d", size)
+		}
+	}
+}
+
+func TestBlockReader_BrokenCRC(t *testing.T) {
+	t.Parallel()
+
+	material := NewMemoryKeyMaterial(&SecureRandomKeyGenerator{})
+	var buf bytes.Buffer
+	w, err := NewBlockWriter(&buf, material)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Bytes()[EncryptedBlockKeySize+3] ^= 1
+
+	r, err := NewBlockReader(bytes.NewReader(buf.Bytes()), material, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(r); err == nil {
+		t.Fatal("expected CRC error")
+	}
+}
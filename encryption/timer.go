@@ -0,0 +1,60 @@
+package encryption
+
+import (
+	"errors"
+	"slices"
+)
+
+// DefaultTimerBits Size of TIM6_CNT, a 16-bit basic timer
+const DefaultTimerBits = 16
+
+// TimerOrigin Candidate counter value a BorlandRand seed was derived from
+type TimerOrigin struct {
+	// Value Counter value the generator was seeded with
+	Value uint32
+	// Calls Number of rand() calls between seeding and seed
+	Calls uint64
+}
+
+// FindTimerOrigins Finds counter values below 2^timerBits that reach seed within maxCalls rand() calls, ordered by fewest calls.
+// Distance is computed for each counter value instead of walking the LCG backwards, so cost does not depend on maxCalls
+func FindTimerOrigins(seed uint32, timerBits int, maxCalls uint64) (origins []TimerOrigin, err error) {
+	if timerBits <= 0 || timerBits > 32 {
+		return nil, errors.New("invalid timer bits")
+	}
+
+	for value := uint64(0); value < 1<<timerBits; value++ {
+		calls, err := BorlandRandLCG.Distance(value, uint64(seed))
+		if err != nil {
+			return nil, err
+		}
+		if calls <= maxCalls {
+			origins = append(origins, TimerOrigin{
+				Value: uint32(value),
+				Calls: calls,
+			})
+		}
+	}
+
+	slices.SortFunc(origins, func(a, b TimerOrigin) int {
+		if a.Calls < b.Calls {
+			return -1
+		} else if a.Calls > b.Calls {
+			return 1
+		}
+		return 0
+	})
+
+	return origins, nil
+}
+
+// IsStuckCounter Whether seeds recovered from separate key blocks of a device show a faulted, static counter,
+// either as a seed known to come from one or as the same seed repeated
+func IsStuckCounter(seeds ...uint32) bool {
+	for i, seed := range seeds {
+		if slices.Contains(stuckCounterSeeds, seed) || slices.Contains(seeds[:i], seed) {
+			return true
+		}
+	}
+	return false
+}
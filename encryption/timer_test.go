@@ -0,0 +1,60 @@
+package encryption
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindTimerOrigins(t *testing.T) {
+	t.Parallel()
+
+	const timer = 0x1234
+	const calls = 300
+
+	// Device seeds from timer, then makes other rand() calls before generating a key
+	generator := BorlandRandKeyGenerator(BorlandRandLCG.Jump(timer, calls))
+	material := NewMemoryKeyMaterial(&generator)
+	b := NewEncryptedBlock(8)
+	if err := b.Encrypt(material); err != nil {
+		t.Fatal(err)
+	}
+
+	seeds, err := BruteforceBorlandSeed(b, material)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, seed := range seeds {
+		origins, err := FindTimerOrigins(seed, DefaultTimerBits, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = found || slices.Contains(origins, TimerOrigin{Value: timer, Calls: calls})
+	}
+	if !found {
+		t.Fatal("timer origin not found")
+	}
+
+	if IsStuckCounter(seeds...) {
+		t.Fatal("unexpected stuck counter")
+	}
+
+	if _, err = FindTimerOrigins(seeds[0], 33, 1000); err == nil {
+		t.Fatal("expected error on invalid timer bits")
+	}
+}
+
+func TestIsStuckCounter(t *testing.T) {
+	t.Parallel()
+
+	if !IsStuckCounter(0x1234, 0) {
+		t.Fatal("expected known stuck seed")
+	}
+	if !IsStuckCounter(0x1234, 0x5678, 0x1234) {
+		t.Fatal("expected repeated seed")
+	}
+	if IsStuckCounter(0x1234, 0x5678) {
+		t.Fatal("unexpected stuck counter")
+	}
+}
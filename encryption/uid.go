@@ -0,0 +1,154 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+const STM32UIDLotSize = 7
+
+// STM32UIDLotCharset Characters seen in lot numbers
+const STM32UIDLotCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ "
+
+// STM32UIDMaxWafer Wafers per lot
+const STM32UIDMaxWafer = 25
+
+// STM32UIDMaxCoordinate Largest plausible die coordinate on a wafer
+const STM32UIDMaxCoordinate = 0xff
+
+// STM32UID Fields of an STM32 96-bit unique device id, as used in DeviceId
+type STM32UID struct {
+	// X, Y Die coordinates on wafer
+	X, Y  uint16
+	Wafer uint8
+	// Lot ASCII lot number
+	Lot [STM32UIDLotSize]byte
+}
+
+// ParseSTM32UID Decodes fields of deviceId
+func ParseSTM32UID(deviceId DeviceId) (u STM32UID) {
+	u.X = uint16(deviceId[0])
+	u.Y = uint16(deviceId[0] >> 16)
+	u.Wafer = uint8(deviceId[1])
+	u.Lot[0] = byte(deviceId[1] >> 8)
+	u.Lot[1] = byte(deviceId[1] >> 16)
+	u.Lot[2] = byte(deviceId[1] >> 24)
+	u.Lot[3] = byte(deviceId[2])
+	u.Lot[4] = byte(deviceId[2] >> 8)
+	u.Lot[5] = byte(deviceId[2] >> 16)
+	u.Lot[6] = byte(deviceId[2] >> 24)
+	return u
+}
+
+func (u STM32UID) DeviceId() (deviceId DeviceId) {
+	deviceId[0] = uint32(u.X) | uint32(u.Y)<<16
+	deviceId[1] = uint32(u.Wafer) | uint32(u.Lot[0])<<8 | uint32(u.Lot[1])<<16 | uint32(u.Lot[2])<<24
+	deviceId[2] = uint32(u.Lot[3]) | uint32(u.Lot[4])<<8 | uint32(u.Lot[5])<<16 | uint32(u.Lot[6])<<24
+	return deviceId
+}
+
+// Plausible Whether fields are within values seen on devices
+func (u STM32UID) Plausible() bool {
+	for _, c := range u.Lot {
+		if !strings.ContainsRune(STM32UIDLotCharset, rune(c)) {
+			return false
+		}
+	}
+	return u.Wafer >= 1 && u.Wafer <= STM32UIDMaxWafer && u.X <= STM32UIDMaxCoordinate && u.Y <= STM32UIDMaxCoordinate
+}
+
+func (u STM32UID) String() string {
+	return fmt.Sprintf("lot %q wafer %d x %d y %d", string(u.Lot[:]), u.Wafer, u.X, u.Y)
+}
+
+// STM32UIDSpace Constrained set of STM32 unique ids, indexable so it can be partitioned across workers.
+// Ranges are inclusive
+type STM32UIDSpace struct {
+	// Lot Known lot number prefix. Remaining characters are enumerated from LotCharset
+	Lot string
+	// LotCharset Defaults to STM32UIDLotCharset
+	LotCharset string
+
+	WaferMin, WaferMax uint8
+	XMin, XMax         uint16
+	YMin, YMax         uint16
+}
+
+// NewSTM32UIDSpace All plausible ids with the lot prefix
+func NewSTM32UIDSpace(lot string) STM32UIDSpace {
+	return STM32UIDSpace{
+		Lot:      lot,
+		WaferMin: 1,
+		WaferMax: STM32UIDMaxWafer,
+		XMax:     STM32UIDMaxCoordinate,
+		YMax:     STM32UIDMaxCoordinate,
+	}
+}
+
+func (s STM32UIDSpace) charset() string {
+	if s.LotCharset == "" {
+		return STM32UIDLotCharset
+	}
+	return s.LotCharset
+}
+
+// Validate Checks the lot prefix, ranges and that the space size fits in an uint64
+func (s STM32UIDSpace) Validate() error {
+	_, err := s.size()
+	return err
+}
+
+// Size Number of ids in s. Panics if s is invalid, see Validate
+func (s STM32UIDSpace) Size() uint64 {
+	size, err := s.size()
+	if err != nil {
+		panic(err)
+	}
+	return size
+}
+
+func (s STM32UIDSpace) size() (uint64, error) {
+	if len(s.Lot) > STM32UIDLotSize {
+		return 0, errors.New("lot too long")
+	} else if s.WaferMin > s.WaferMax || s.XMin > s.XMax || s.YMin > s.YMax {
+		return 0, errors.New("invalid range")
+	}
+
+	size := (uint64(s.WaferMax-s.WaferMin) + 1) * (uint64(s.XMax-s.XMin) + 1) * (uint64(s.YMax-s.YMin) + 1)
+	for i := len(s.Lot); i < STM32UIDLotSize; i++ {
+		hi, lo := bits.Mul64(size, uint64(len(s.charset())))
+		if hi != 0 {
+			return 0, errors.New("space too large")
+		}
+		size = lo
+	}
+	return size, nil
+}
+
+func (s STM32UIDSpace) UID(index uint64) (u STM32UID) {
+	n := uint64(s.XMax-s.XMin) + 1
+	u.X = s.XMin + uint16(index%n)
+	index /= n
+
+	n = uint64(s.YMax-s.YMin) + 1
+	u.Y = s.YMin + uint16(index%n)
+	index /= n
+
+	n = uint64(s.WaferMax-s.WaferMin) + 1
+	u.Wafer = s.WaferMin + uint8(index%n)
+	index /= n
+
+	charset := s.charset()
+	copy(u.Lot[:], s.Lot)
+	for i := len(s.Lot); i < STM32UIDLotSize; i++ {
+		u.Lot[i] = charset[index%uint64(len(charset))]
+		index /= uint64(len(charset))
+	}
+	return u
+}
+
+func (s STM32UIDSpace) DeviceId(index uint64) DeviceId {
+	return s.UID(index).DeviceId()
+}
@@ -0,0 +1,85 @@
+package firmware
+
+import (
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"slices"
+)
+
+// EncryptBlockData Optionally compresses, then encrypts data into a new block.
+// The returned header has Size and SizeAligned set, as Size is the only record of compressed length.
+// When compressed, the key block holds the CRC of the uncompressed data, as the device verifies it after decompression
+func EncryptBlockData(data []byte, compress bool, material encryption.KeyMaterial) (b encryption.EncryptedBlock, header ASBlockHeader, err error) {
+	payload := data
+	if compress {
+		if payload, err = compression.FirmwareBlockCompress(data, false); err != nil {
+			return nil, header, err
+		}
+	}
+
+	header = ASBlockHeader{
+		HeaderSize:  BlockHeaderSize,
+		Size:        uint32(len(payload)),
+		SizeAligned: uint32(len(payload)),
+	}
+	if (header.SizeAligned % 8) != 0 {
+		header.SizeAligned = (header.SizeAligned & uint32(0xFFFFFFF8)) + 8
+	}
+
+	b = encryption.NewEncryptedBlock(int(header.SizeAligned))
+	copy(b.DataBlock(), payload)
+
+	crcValue := material.CalculateCRC(b.DataBlock())
+	if compress {
+		crcValue = material.CalculateCRC(data)
+	}
+	if err = b.EncryptWithCRC(material, crcValue); err != nil {
+		return nil, header, err
+	}
+
+	return b, header, nil
+}
+
+// DecryptBlockData Decrypts a copy of b and returns its data once its CRC is verified, as a device would.
+// compressed is the Compressed flag of the firmware file header, as blocks do not record it, and selects which data the CRC covers:
+// uncompressed blocks are verified whole, including padding up to header.SizeAligned, and their first header.Size bytes returned,
+// while compressed blocks are decompressed from header.Size bytes and verified over the uncompressed data.
+// material must match the one used to encrypt, its Generator is not used
+func DecryptBlockData(b encryption.EncryptedBlock, header ASBlockHeader, compressed bool, material encryption.KeyMaterial) ([]byte, error) {
+	decBlock := slices.Clone(b)
+
+	if err := decBlock.Decrypt(material, !compressed); err != nil {
+		return nil, err
+	}
+
+	if int(header.Size) > len(decBlock.DataBlock()) {
+		return nil, errors.New("block size larger than data")
+	}
+
+	if !compressed {
+		return decBlock.DataBlock()[:header.Size], nil
+	}
+
+	data, err := compression.FirmwareBlockDecompress(decBlock.DataBlock()[:header.Size])
+	if err != nil {
+		return nil, err
+	}
+
+	crc1, _ := decBlock.CRC()
+	if calculatedCrc := material.CalculateCRC(data); calculatedCrc != crc1 {
+		return nil, fmt.Errorf("data CRC not matching: expected %08x, got %08x", crc1, calculatedCrc)
+	}
+
+	return data, nil
+}
+
+// DecryptBlockFlashData As DecryptBlockData, returning uncompressed blocks whole, including padding up to header.SizeAligned,
+// as the device writes them to flash
+func DecryptBlockFlashData(b encryption.EncryptedBlock, header ASBlockHeader, compressed bool, material encryption.KeyMaterial) ([]byte, error) {
+	if !compressed {
+		header.Size = uint32(len(b.DataBlock()))
+	}
+	return DecryptBlockData(b, header, compressed, material)
+}
@@ -0,0 +1,99 @@
+package firmware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestEncryptBlockData(t *testing.T) {
+	t.Parallel()
+
+	// Partially random, so compressed size is not aligned
+	data := make([]byte, DefaultBlockSize-3)
+	if _, err := io.ReadFull(rand.Reader, data[:0x123]); err != nil {
+		t.Fatal(err)
+	}
+
+	material := encryption.NewFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{})
+
+	for _, compressed := range []bool{false, true} {
+		b, header, err := EncryptBlockData(data, compressed, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.SizeAligned%8 != 0 || int(header.SizeAligned) != len(b.DataBlock()) || header.Size > header.SizeAligned {
+			t.Fatalf("invalid header sizes %d, %d", header.Size, header.SizeAligned)
+		}
+		if compressed && int(header.Size) >= len(data) {
+			t.Fatal("data not compressed")
+		}
+
+		result, err := DecryptBlockData(b, header, compressed, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(result, data) {
+			t.Fatal("data mismatch")
+		}
+
+		flashData, err := DecryptBlockFlashData(b, header, compressed, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !compressed {
+			// Uncompressed data is written to flash with padding
+			if len(flashData) != int(header.SizeAligned) || !bytes.Equal(flashData[len(data):], make([]byte, len(flashData)-len(data))) {
+				t.Fatalf("expected %d bytes with zero padding, got %d", header.SizeAligned, len(flashData))
+			}
+			flashData = flashData[:len(data)]
+		}
+		if !bytes.Equal(flashData, data) {
+			t.Fatal("flash data mismatch")
+		}
+
+		// Decrypted block data is not the input when compressed, and must not be verified as such
+		if _, err = DecryptBlockData(b, header, !compressed, material); err == nil {
+			t.Fatal("expected error with wrong compression flag")
+		}
+
+		b.DataBlock()[0] ^= 1
+		if _, err = DecryptBlockData(b, header, compressed, material); err == nil {
+			t.Fatal("expected error with broken data")
+		}
+	}
+}
+
+func TestEncryptBlockData_DataCRC(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 0x400)
+	material := encryption.NewFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{})
+
+	b, header, err := EncryptBlockData(data, true, material)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Key block holds the CRC of uncompressed data, not of block data
+	decBlock := slices.Clone(b)
+	if err = decBlock.Decrypt(material, false); err != nil {
+		t.Fatal(err)
+	}
+	if crc1, crc2 := decBlock.CRC(); crc1 != crc2 || crc1 != material.CalculateCRC(data) {
+		t.Fatalf("unexpected CRC %08x, %08x", crc1, crc2)
+	}
+
+	// Same block with an explicit CRC
+	forged := encryption.NewEncryptedBlock(int(header.SizeAligned))
+	copy(forged.DataBlock(), decBlock.DataBlock())
+	if err = forged.EncryptWithCRC(material, material.CalculateCRC(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptBlockData(forged, header, true, material); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package firmware
+
+import (
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+)
+
+// DefaultBlockSize Amount of code placed on each block by NewEntry
+const DefaultBlockSize = 0x1000
+
+// NewBlock Encrypts data into a Block to be programmed at addr.
+// When compressed, the CRC in the key block is calculated over the uncompressed data
+func NewBlock(addr uint32, data []byte, compressed bool, material encryption.KeyMaterial) (Block, error) {
+	b, header, err := EncryptBlockData(data, compressed, material)
+	if err != nil {
+		return Block{}, err
+	}
+	header.Addr = addr
+
+	return Block{
+		Header: header,
+		Block:  b,
+	}, nil
+}
+
+// NewEntry Splits code to be placed at addr into blocks of up to blockSize
+func NewEntry(code []byte, addr uint32, blockSize int, compressed bool, material encryption.KeyMaterial) (entry Entry, err error) {
+	if blockSize <= 0 || blockSize%8 != 0 {
+		return entry, errors.New("block size must be % 8")
+	} else if compressed && blockSize > compression.DataMaxSize {
+		return entry, errors.New("block size too large for compression")
+	}
+
+	entry.compressed = compressed
+	for offset := 0; offset < len(code); offset += blockSize {
+		var block Block
+		if block, err = NewBlock(addr+uint32(offset), code[offset:min(len(code), offset+blockSize)], compressed, material); err != nil {
+			return entry, err
+		}
+		entry.Blocks = append(entry.Blocks, block)
+		entry.Header.DataSize += uint32(BlockHeaderSize + len(block.Block))
+	}
+
+	return entry, nil
+}
+
+// FileHeaderSize Size of ASFileHeader as encoded by NewFirmware, excluding UpdateCRC32
+const FileHeaderSize = MarkerSize + 4*3 + SerialNumberSize + 2 + 4*2 + 2 + 4
+
+// NewFirmware Encodes entry as a Phyton firmware file, with DateTime, BufferSize, SerialNumber and versions taken from header.
+// For device locked firmware, set SerialNumber, build entry with encryption.NewDeviceFlashKeyMaterial and set its DeviceId
+func NewFirmware(header ASFileHeader, entry Entry) (*Firmware, error) {
+	data := entry.Blocks.Bytes()
+
+	var compressed uint8
+	if entry.compressed {
+		compressed = 1
+	}
+
+	buf := make([]byte, 0, FileHeaderSize+len(data))
+	buf = append(buf, MarkerPhyton...)
+	buf = binary.LittleEndian.AppendUint32(buf, FileHeaderSize)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(header.DateTime))
+	buf = binary.LittleEndian.AppendUint32(buf, header.BufferSize)
+	buf = append(buf, header.SerialNumber[:]...)
+	buf = append(buf, header.VersionLow, header.VersionHigh)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = append(buf, compressed, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, crc.CalculateCRC(data))
+	buf = append(buf, data...)
+
+	return LoadDeviceFirmware(buf, entry.DeviceId)
+}
@@ -0,0 +1,62 @@
+package firmware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"io"
+	"testing"
+)
+
+func TestNewFirmware_DeviceLocked(t *testing.T) {
+	t.Parallel()
+
+	deviceId := encryption.DeviceId{0x003B0056, 0x4D4B5002, 0x20323455}
+
+	code := make([]byte, DefaultBlockSize*2+0x128)
+	if _, err := io.ReadFull(rand.Reader, code); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compressed := range []bool{false, true} {
+		entry, err := NewEntry(code, BaseAddress, DefaultBlockSize, compressed, encryption.NewDeviceFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{}, deviceId))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.DeviceId = &deviceId
+
+		var header ASFileHeader
+		copy(header.SerialNumber[:], "RC-102-000123")
+		fw, err := NewFirmware(header, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !fw.FileHeader.IsSerialLocked() || fw.FileHeader.SerialNumberString() != "RC-102-000123" {
+			t.Fatal("expected serial locked firmware")
+		}
+		if (fw.FileHeader.Compressed > 0) != compressed {
+			t.Fatal("compressed flag mismatch")
+		}
+		if !bytes.Equal(fw.Entries[0].Code(), code) {
+			t.Fatal("code mismatch")
+		}
+
+		// Blocks cannot be decrypted without the device id
+		fw, err = LoadFirmware(fw.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Entries[0].DecryptCode(); err == nil {
+			t.Fatal("expected code error without device id")
+		}
+		b := fw.Entries[0].Blocks[0].Block
+		material, err := fw.Entries[0].KeyMaterial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = b.Decrypt(material, false); err == nil {
+			t.Fatal("expected error without device id")
+		}
+	}
+}
@@ -2,10 +2,10 @@ package firmware
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/buffer"
-	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/compression"
 	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/crc"
 	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
 	"golang.org/x/text/encoding/charmap"
@@ -61,6 +61,17 @@ func (h ASFileHeader) IsAlmaCode() bool {
 	return bytes.Compare(h.Marker[:], []byte(MarkerAlmaCode)) == 0
 }
 
+// IsSerialLocked Firmware coded for a specific device S/N, and its blocks may require its DeviceId
+func (h ASFileHeader) IsSerialLocked() bool {
+	return slices.ContainsFunc(h.SerialNumber[:], func(b byte) bool {
+		return b != 0
+	})
+}
+
+func (h ASFileHeader) SerialNumberString() string {
+	return string(zeroTerminatedSlice(h.SerialNumber[:]))
+}
+
 type ASBlockHeader struct {
 	HeaderSize uint32
 	Size       uint32
@@ -74,54 +85,76 @@ type Block struct {
 	Block  encryption.EncryptedBlock
 }
 
+// BlockHeaderSize Size of ASBlockHeader as encoded, excluding SizeAligned
+const BlockHeaderSize = 4 * 3
+
+// Bytes Encodes the block as found in firmware files
+func (b Block) Bytes() []byte {
+	buf := make([]byte, 0, BlockHeaderSize+len(b.Block))
+	buf = binary.LittleEndian.AppendUint32(buf, BlockHeaderSize)
+	buf = binary.LittleEndian.AppendUint32(buf, b.Header.Size^BlockHeaderSizeKey)
+	buf = binary.LittleEndian.AppendUint32(buf, b.Header.Addr^BlockHeaderAddrKey)
+	return append(buf, b.Block...)
+}
+
 type Entry struct {
-	Header     ASFirmwareHeader
-	Blocks     Blocks
+	Header ASFirmwareHeader
+	Blocks Blocks
+	// DeviceId Optional, required to decrypt blocks locked to a device
+	DeviceId *encryption.DeviceId
+	// Profile Optional, encryption.DefaultKeyProfile is used if unset
+	Profile    *encryption.KeyProfile
 	compressed bool
 }
 
+// KeyMaterial Material to decrypt blocks, device locked if DeviceId is set
+func (entry Entry) KeyMaterial() (encryption.KeyMaterial, error) {
+	profile := entry.Profile
+	if profile == nil {
+		profile = encryption.DefaultKeyProfile()
+	}
+	if entry.DeviceId != nil {
+		return profile.DeviceFlashKeyMaterial(nil, *entry.DeviceId)
+	}
+	return profile.FlashKeyMaterial(nil)
+}
+
+// Code As DecryptCode, panicking on failure
 func (entry Entry) Code() []byte {
+	buf, err := entry.DecryptCode()
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// DecryptCode Decrypts all blocks and places them at their address, relative to BaseAddress
+func (entry Entry) DecryptCode() ([]byte, error) {
 	// Runs https://www.st.com/en/microcontrollers-microprocessors/stm32l475vc.html
 	// https://youtu.be/-IsAlSwFWIA?t=508
 	var buf []byte
+	material, err := entry.KeyMaterial()
+	if err != nil {
+		return nil, err
+	}
 	for _, b := range entry.Blocks {
-		decBlock := slices.Clone(b.Block)
-
-		err := decBlock.Decrypt(encryption.NewFlashKeyMaterial(nil), !entry.compressed)
+		data, err := DecryptBlockFlashData(b.Block, b.Header, entry.compressed, material)
 		if err != nil {
-			panic(err)
+			return nil, err
+		}
+		if b.Header.Addr < BaseAddress {
+			return nil, fmt.Errorf("block address %08x below base address", b.Header.Addr)
 		}
-		if entry.compressed {
-			data, err := compression.FirmwareBlockDecompress(decBlock.DataBlock()[:b.Header.Size])
-			if err != nil {
-				panic(err)
-			}
-
-			calculatedCrc := crc.CalculateCRC(data)
-
-			crc1, _ := decBlock.CRC()
-
-			if calculatedCrc != crc1 {
-				panic("wrong CRC")
-			}
-
-			newLength := int(b.Header.Addr) - BaseAddress + len(data)
-			if len(buf) < newLength {
-				buf = append(buf, make([]byte, newLength-len(buf))...)
-			}
-
-			copy(buf[int(b.Header.Addr)-BaseAddress:], data)
-		} else {
-			newLength := int(b.Header.Addr) - BaseAddress + len(decBlock.DataBlock())
-			if len(buf) < newLength {
-				buf = append(buf, make([]byte, newLength-len(buf))...)
-			}
 
-			copy(buf[int(b.Header.Addr)-BaseAddress:], decBlock.DataBlock())
+		newLength := int(b.Header.Addr) - BaseAddress + len(data)
+		if len(buf) < newLength {
+			buf = append(buf, make([]byte, newLength-len(buf))...)
 		}
+
+		copy(buf[int(b.Header.Addr)-BaseAddress:], data)
 	}
 
-	return buf
+	return buf, nil
 }
 
 type Firmware struct {
@@ -134,17 +167,46 @@ type Firmware struct {
 
 type Blocks []Block
 
-func BlocksFromData(data []byte) (result Blocks) {
-	inputData := buffer.PanicBuffer(data)
+func (b Blocks) Bytes() (buf []byte) {
+	for _, block := range b {
+		buf = append(buf, block.Bytes()...)
+	}
+	return buf
+}
+
+// BlocksFromData As ParseBlocks, panicking on malformed data
+func BlocksFromData(data []byte) Blocks {
+	result, err := ParseBlocks(data)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ParseBlocks Decodes consecutive blocks filling data. Sizes are checked against data before allocating, as data may be untrusted
+func ParseBlocks(data []byte) (result Blocks, err error) {
+	inputData := buffer.Buffer(data)
 
 	for len(inputData) > 0 {
 		var blockHeader ASBlockHeader
-		blockHeader.HeaderSize = inputData.ReadUint32()
+		if blockHeader.HeaderSize, err = inputData.ReadUint32(); err != nil {
+			return nil, err
+		}
 
 		//i := int32(-836261582)
-		blockHeader.Size = inputData.ReadUint32() ^ BlockHeaderSizeKey
+		if blockHeader.Size, err = inputData.ReadUint32(); err != nil {
+			return nil, err
+		}
+		blockHeader.Size ^= BlockHeaderSizeKey
 		//i2 := int32(-1294620572)
-		blockHeader.Addr = inputData.ReadUint32() ^ BlockHeaderAddrKey
+		if blockHeader.Addr, err = inputData.ReadUint32(); err != nil {
+			return nil, err
+		}
+		blockHeader.Addr ^= BlockHeaderAddrKey
+
+		if uint64(blockHeader.Size) > uint64(len(inputData)) {
+			return nil, io.ErrUnexpectedEOF
+		}
 		blockHeader.SizeAligned = blockHeader.Size
 		//i3 := int32(-8)
 		if (blockHeader.SizeAligned % 8) != 0 {
@@ -152,8 +214,8 @@ func BlocksFromData(data []byte) (result Blocks) {
 		}
 
 		encryptedBlock := encryption.NewEncryptedBlock(int(blockHeader.SizeAligned))
-		if n := inputData.Read(encryptedBlock); n != len(encryptedBlock) {
-			panic(io.ErrUnexpectedEOF)
+		if _, err = io.ReadFull(&inputData, encryptedBlock); err != nil {
+			return nil, io.ErrUnexpectedEOF
 		}
 
 		result = append(result, Block{
@@ -162,7 +224,14 @@ func BlocksFromData(data []byte) (result Blocks) {
 		})
 	}
 
-	return result
+	return result, nil
+}
+
+// SetKeyProfile Sets profile on all entries
+func (fw *Firmware) SetKeyProfile(profile *encryption.KeyProfile) {
+	for i := range fw.Entries {
+		fw.Entries[i].Profile = profile
+	}
 }
 
 func (fw Firmware) Length() int {
@@ -173,6 +242,11 @@ func (fw Firmware) Length() int {
 }
 
 func LoadFirmware(buf []byte) (f *Firmware, err error) {
+	return LoadDeviceFirmware(buf, nil)
+}
+
+// LoadDeviceFirmware As LoadFirmware, with deviceId set on all entries to decrypt device locked blocks. deviceId may be nil
+func LoadDeviceFirmware(buf []byte, deviceId *encryption.DeviceId) (f *Firmware, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			var ok bool
@@ -270,6 +344,7 @@ func LoadFirmware(buf []byte) (f *Firmware, err error) {
 			entry.Header.DateTime = DateTime(dateTime)
 			entry.Header.DataSize, err = fileBuffer.ReadUint32()
 			entry.compressed = fw.FileHeader.Compressed > 0
+			entry.DeviceId = deviceId
 			if err != nil {
 				return nil, err
 			}
@@ -292,6 +367,7 @@ func LoadFirmware(buf []byte) (f *Firmware, err error) {
 				DataSize: uint32(len(fw.Data[fw.FileHeader.HeaderSize:])),
 			},
 			Blocks:     BlocksFromData(fw.Data[fw.FileHeader.HeaderSize:]),
+			DeviceId:   deviceId,
 			compressed: fw.FileHeader.Compressed > 0,
 		})
 	}
@@ -33,6 +33,14 @@ type FlashAreaData_PublicAPI struct {
 	TargetStartTimeout  uint32
 }
 
+// Bytes Encodes the area as stored on device, including PublicSignature and StructLen
+func (f *FlashAreaData) Bytes() []byte {
+	buf := make([]byte, 8, 8+len(f.Data))
+	binary.LittleEndian.PutUint32(buf, f.PublicSignature)
+	binary.LittleEndian.PutUint32(buf[4:], f.StructLen)
+	return append(buf, f.Data...)
+}
+
 func (f *FlashAreaData) PublicAPI() *FlashAreaData_PublicAPI {
 	var result FlashAreaData_PublicAPI
 	err := binary.Read(bytes.NewReader(f.Data), binary.LittleEndian, &result)
@@ -69,6 +77,69 @@ const (
 	FlashRdpErr
 )
 
+var flashStatusNames = [...]string{
+	FlashOK:              "FLASH_OK",
+	FlashInvAddr:         "FLASH_INV_ADDR",
+	FlashWrProt:          "FLASH_WR_PROT",
+	FlashNotBlank:        "FLASH_NOT_BLANK",
+	FlashVerify:          "FLASH_VERIFY",
+	FlashErase:           "FLASH_ERASE",
+	FlashProg:            "FLASH_PROG",
+	FlashInitEr:          "FLASH_INIT_ER",
+	FlashSignEr:          "FLASH_SIGN_ER",
+	FlashInvalidCRC:      "FLASH_INVALID_CRC",
+	FlashInvalidKeyNumb:  "FLASH_INVALID_KEY_NUMB",
+	FlashInvalidSign:     "FLASH_INVALID_SIGN",
+	FlashInvalidAreaName: "FLASH_INVALID_AREA_NAME",
+	FlashInvalidTarget:   "FLASH_INVALID_TARGET",
+	FlashRdpErr:          "FLASH_RDP_ERR",
+}
+
+func (s FlashStatus) String() string {
+	if int(s) < len(flashStatusNames) {
+		return flashStatusNames[s]
+	}
+	return fmt.Sprintf("FlashStatus(%d)", uint32(s))
+}
+
+// EncodeReadFlashArea Encodes a result of RD_FLASH_AREA command, as sent by device. Area data is masked starting from seed
+func EncodeReadFlashArea(status FlashStatus, area *FlashAreaData, seed uint32) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(status))
+	if status != FlashOK {
+		return buf
+	}
+
+	data := area.Bytes()
+	buf = binary.LittleEndian.AppendUint32(buf, seed)
+
+	var size uint32
+	size, seed = encryption.BorlandRandXORUint32(uint32(len(data)), seed)
+	buf = binary.LittleEndian.AppendUint32(buf, size)
+
+	encryption.BorlandRandXORInPlace(data, seed)
+	return append(buf, data...)
+}
+
+// ReadFlashAreaMask XOR mask applied to size and size bytes of area data of a RD_FLASH_AREA result masked from seed.
+// Also returns the generator seed after masking
+func ReadFlashAreaMask(seed uint32, size int) (mask []byte, nextSeed uint32) {
+	mask = make([]byte, 4+size)
+	nextSeed = encryption.BorlandRandXORInPlace(mask, seed)
+	return mask, nextSeed
+}
+
+// PredictReadFlashAreaMasks Masks of the next n RD_FLASH_AREA results of size bytes of area data, with their seeds,
+// when the device does not reseed nor call rand() in between, and each result is masked from the seed following the previous one
+func PredictReadFlashAreaMasks(seed uint32, size, n int) (seeds []uint32, masks [][]byte) {
+	for i := 0; i < n; i++ {
+		var mask []byte
+		seeds = append(seeds, seed)
+		mask, seed = ReadFlashAreaMask(seed, size)
+		masks = append(masks, mask)
+	}
+	return seeds, masks
+}
+
 // DecodeReadFlashArea Decodes a result of RD_FLASH_AREA command
 func DecodeReadFlashArea(data []byte) (*FlashAreaData, error) {
 	buf := buffer.Buffer(data)
@@ -0,0 +1,68 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"testing"
+)
+
+func TestReadFlashAreaMask(t *testing.T) {
+	t.Parallel()
+
+	area := &FlashAreaData{
+		PublicSignature: 0x12345678,
+		Data:            []byte("calibration data"),
+	}
+	area.StructLen = uint32(len(area.Data))
+
+	const seed = 0xcafe
+	encoded := EncodeReadFlashArea(FlashOK, area, seed)
+
+	plain := area.Bytes()
+	mask, _ := ReadFlashAreaMask(seed, len(plain))
+	masked := encoded[4*2:]
+	for i := range masked {
+		masked[i] ^= mask[i]
+	}
+
+	if masked[0] != byte(len(plain)) || !bytes.Equal(masked[4:], plain) {
+		t.Fatal("mask mismatch")
+	}
+}
+
+func TestPredictReadFlashAreaMasks(t *testing.T) {
+	t.Parallel()
+
+	area := &FlashAreaData{
+		PublicSignature: 0x12345678,
+		Data:            []byte("calibration data"),
+	}
+	area.StructLen = uint32(len(area.Data))
+	plain := area.Bytes()
+
+	const seed = 0xcafe
+	seeds, masks := PredictReadFlashAreaMasks(seed, len(plain), 3)
+	if len(seeds) != 3 || len(masks) != 3 || seeds[0] != seed {
+		t.Fatal("unexpected predictions")
+	}
+
+	next := uint32(seed)
+	for i := range masks {
+		encoded := EncodeReadFlashArea(FlashOK, area, next)
+		if binary.LittleEndian.Uint32(encoded[4:]) != seeds[i] {
+			t.Fatalf("seed mismatch at %d", i)
+		}
+
+		masked := encoded[4*2:]
+		for j := range masked {
+			masked[j] ^= masks[i][j]
+		}
+		if masked[0] != byte(len(plain)) || !bytes.Equal(masked[4:], plain) {
+			t.Fatalf("mask mismatch at %d", i)
+		}
+
+		// Device masks the next result from where the generator stopped
+		next = encryption.BorlandRandXORInPlace(make([]byte, len(masks[i])), next)
+	}
+}
@@ -4,4 +4,4 @@ go 1.21
 
 require golang.org/x/text v0.13.0
 
-require github.com/icza/bitio v1.1.0 // indirect
+require github.com/icza/bitio v1.1.0
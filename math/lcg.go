@@ -0,0 +1,100 @@
+package math
+
+import "errors"
+
+// LCG Linear congruential generator with power of two modulus, state = (state * Multiplier + Addend) mod 2^ModulusBits
+type LCG struct {
+	Multiplier uint64
+	Addend     uint64
+	// ModulusBits From 1 to 64
+	ModulusBits int
+
+	OutputShift int
+	OutputMask  uint64
+}
+
+func (g LCG) modulusMask() uint64 {
+	if g.ModulusBits <= 0 || g.ModulusBits > 64 {
+		panic("invalid modulus bits")
+	}
+	return ^uint64(0) >> (64 - g.ModulusBits)
+}
+
+// Next Steps state forward once
+func (g LCG) Next(state uint64) uint64 {
+	return (state*g.Multiplier + g.Addend) & g.modulusMask()
+}
+
+// Previous Steps state backwards once. Multiplier must be odd
+func (g LCG) Previous(state uint64) uint64 {
+	return g.JumpBack(state, 1)
+}
+
+// Output Output bits of state
+func (g LCG) Output(state uint64) uint64 {
+	return (state >> g.OutputShift) & g.OutputMask
+}
+
+// Jump Steps state forward n times, in O(log n)
+func (g LCG) Jump(state, n uint64) uint64 {
+	mask := g.modulusMask()
+
+	// Composes the affine map state * a + c with itself by squaring
+	accMultiplier, accAddend := uint64(1), uint64(0)
+	multiplier, addend := g.Multiplier, g.Addend
+	for ; n > 0; n >>= 1 {
+		if n&1 > 0 {
+			accMultiplier = (accMultiplier * multiplier) & mask
+			accAddend = (accAddend*multiplier + addend) & mask
+		}
+		addend = ((multiplier + 1) * addend) & mask
+		multiplier = (multiplier * multiplier) & mask
+	}
+
+	return (state*accMultiplier + accAddend) & mask
+}
+
+// JumpBack Steps state backwards n times, in O(log n). Multiplier must be odd
+func (g LCG) JumpBack(state, n uint64) uint64 {
+	if g.Multiplier&1 == 0 {
+		panic("multiplier must be odd")
+	}
+	// With odd multiplier, stepping 2^ModulusBits times is the identity
+	return g.Jump(state, (-n)&g.modulusMask())
+}
+
+// FullPeriod Whether all 2^ModulusBits states are visited, per Hull-Dobell theorem
+func (g LCG) FullPeriod() bool {
+	if g.ModulusBits == 1 {
+		return g.Addend&1 == 1
+	}
+	return g.Addend&1 == 1 && g.Multiplier&3 == 1
+}
+
+// Distance Number of steps forward from state to reach target, such that Jump(from, n) == to.
+// Solved bit by bit: on a full period generator, stepping 2^i times keeps the lowest i bits and flips bit i
+func (g LCG) Distance(from, to uint64) (n uint64, err error) {
+	if !g.FullPeriod() {
+		return 0, errors.New("generator does not have full period")
+	}
+
+	mask := g.modulusMask()
+	from &= mask
+	to &= mask
+
+	multiplier, addend := g.Multiplier, g.Addend
+	for i := 0; i < g.ModulusBits; i++ {
+		if (from^to)&(1<<i) > 0 {
+			from = (from*multiplier + addend) & mask
+			n |= 1 << i
+		}
+		addend = ((multiplier + 1) * addend) & mask
+		multiplier = (multiplier * multiplier) & mask
+	}
+
+	if from != to {
+		return 0, errors.New("state not reachable")
+	}
+
+	return n, nil
+}
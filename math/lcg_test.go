@@ -0,0 +1,69 @@
+package math
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var testBorlandLCG = LCG{
+	Multiplier:  0x015A4E35,
+	Addend:      1,
+	ModulusBits: 32,
+	OutputShift: 16,
+	OutputMask:  0x7FFF,
+}
+
+var testJavaLCG = LCG{
+	Multiplier:  0x5DEECE66D,
+	Addend:      11,
+	ModulusBits: 48,
+	OutputShift: 48 - 16,
+	OutputMask:  0xFFFF,
+}
+
+func TestLCG_Jump(t *testing.T) {
+	t.Parallel()
+
+	for _, g := range []LCG{testBorlandLCG, testJavaLCG} {
+		state := rand.Uint64() & g.modulusMask()
+
+		next := state
+		for n := uint64(0); n < 1000; n++ {
+			if jumped := g.Jump(state, n); jumped != next {
+				t.Fatalf("jump %d: expected %x, got %x", n, next, jumped)
+			}
+			if back := g.JumpBack(next, n); back != state {
+				t.Fatalf("jump back %d: expected %x, got %x", n, state, back)
+			}
+			if g.Previous(g.Next(next)) != next {
+				t.Fatalf("previous %d: mismatch", n)
+			}
+			next = g.Next(next)
+		}
+	}
+}
+
+func TestLCG_Distance(t *testing.T) {
+	t.Parallel()
+
+	for _, g := range []LCG{testBorlandLCG, testJavaLCG} {
+		for i := 0; i < 100; i++ {
+			state := rand.Uint64() & g.modulusMask()
+			n := rand.Uint64() & g.modulusMask()
+
+			distance, err := g.Distance(state, g.Jump(state, n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if distance != n {
+				t.Fatalf("expected distance %d, got %d", n, distance)
+			}
+		}
+	}
+
+	g := testBorlandLCG
+	g.Multiplier = 3
+	if _, err := g.Distance(0, 1); err == nil {
+		t.Fatal("expected error on generator without full period")
+	}
+}
@@ -1,38 +1,101 @@
 package math
 
+import "math/big"
+
+// ModularMultiplicativeInverse Returns 0 if number has no inverse modulo
 func ModularMultiplicativeInverse(number, modulo uint64) uint64 {
-	for x := uint64(1); x < modulo && x != 0; x++ {
-		if ((number%modulo)*(x%modulo))%modulo == 1 {
-			return x
-		}
+	inverse, _ := ModularInverse(number, modulo)
+	return inverse
+}
+
+// ModularMultiplicativeInverseBits Inverse modulo 2^bits. Returns 0 if number is even
+func ModularMultiplicativeInverseBits(number uint64, bits int) uint64 {
+	inverse, _ := ModularInverseBits(number, bits)
+	return inverse
+}
+
+// ModularMultiplicativeInverseFixed Inverse modulo 2^N, N being the bit size of T. Returns 0 if number is even
+func ModularMultiplicativeInverseFixed[T ~int32 | ~uint32 | ~int64 | ~uint64](number T) T {
+	if number&1 == 0 {
+		return 0
 	}
 
-	return 0
+	// Newton iteration, x = x * (2 - number * x) doubles correct low bits each step.
+	// number is its own inverse modulo 2^3, so five steps reach 96 bits
+	x := number
+	for i := 0; i < 5; i++ {
+		x *= 2 - number*x
+	}
+	return x
 }
 
-func ModularMultiplicativeInverseBits(number uint64, bits int) uint64 {
-	modulo := (uint64(1) << bits) - 1
-	for x := uint64(1); x < modulo && x != 0; x++ {
-		if ((number&modulo)*(x&modulo))&modulo == 1 {
-			return x
-		}
+// ModularInverse Inverse of number modulo, via extended Euclidean algorithm
+func ModularInverse(number, modulo uint64) (inverse uint64, ok bool) {
+	if modulo == 0 {
+		return 0, false
+	} else if modulo == 1 {
+		return 0, true
+	}
+
+	// Bezout coefficients alternate in sign, so only magnitudes are kept. These grow up to modulo, and do not overflow.
+	// positive tracks the sign of the coefficient in t0, the first being t1 = 1
+	r0, r1 := modulo, number%modulo
+	t0, t1 := uint64(0), uint64(1)
+	var positive bool
+	for r1 != 0 {
+		q := r0 / r1
+		r0, r1 = r1, r0-q*r1
+		t0, t1 = t1, t0+q*t1
+		positive = !positive
 	}
 
-	return 0
+	if r0 != 1 {
+		return 0, false
+	}
+
+	if !positive {
+		return modulo - t0, true
+	}
+	return t0, true
 }
 
-func ModularMultiplicativeInverseFixed[T ~int32 | ~uint32 | ~int64 | ~uint64](number T) T {
-	var modulo T
-	modulo--
+// ModularInverseBits Inverse of number modulo 2^bits, via Hensel lifting
+func ModularInverseBits(number uint64, bits int) (inverse uint64, ok bool) {
+	if bits <= 0 || bits > 64 {
+		panic("invalid bits")
+	} else if number&1 == 0 {
+		return 0, false
+	}
 
-	var one T
-	one++
+	return ModularMultiplicativeInverseFixed(number) & (^uint64(0) >> (64 - bits)), true
+}
 
-	for x := one; x < modulo && x != 0; x++ {
-		if number*x == 1 {
-			return x
-		}
+// ModularInverseBig Inverse of number modulo, or nil if none exists
+func ModularInverseBig(number, modulo *big.Int) *big.Int {
+	if modulo.Sign() <= 0 {
+		return nil
+	}
+	return new(big.Int).ModInverse(number, modulo)
+}
+
+// ModularInverseBitsBig Inverse of number modulo 2^n, via Hensel lifting, or nil if number is even
+func ModularInverseBitsBig(number *big.Int, n int) *big.Int {
+	if n <= 0 {
+		panic("invalid bits")
+	} else if number.Bit(0) == 0 {
+		return nil
 	}
 
-	return 0
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(n)), big.NewInt(1))
+
+	two := big.NewInt(2)
+	t := new(big.Int)
+	x := new(big.Int).And(number, mask)
+	for correct := 3; correct < n; correct *= 2 {
+		t.Mul(number, x)
+		t.Sub(two, t)
+		x.Mul(x, t)
+		x.And(x, mask)
+	}
+	return x.And(x, mask)
 }
@@ -0,0 +1,90 @@
+package math
+
+import (
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// mulMod (a * b) mod modulo without overflow
+func mulMod(a, b, modulo uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi%modulo, lo, modulo)
+	return rem
+}
+
+func TestModularInverse(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 1000; i++ {
+		number, modulo := rand.Uint64(), rand.Uint64()|1
+		if i%2 == 0 {
+			// Prime modulo
+			modulo = 0xFFFFFFFFFFFFFFC5
+		}
+
+		inverse, ok := ModularInverse(number, modulo)
+		if gcd := new(big.Int).GCD(nil, nil, new(big.Int).SetUint64(number), new(big.Int).SetUint64(modulo)); gcd.Cmp(big.NewInt(1)) != 0 {
+			if ok {
+				t.Fatalf("%d has no inverse modulo %d", number, modulo)
+			}
+			continue
+		}
+
+		if !ok || mulMod(number, inverse, modulo) != 1 {
+			t.Fatalf("wrong inverse %d of %d modulo %d", inverse, number, modulo)
+		}
+
+		if expected := ModularInverseBig(new(big.Int).SetUint64(number), new(big.Int).SetUint64(modulo)); expected.Uint64() != inverse {
+			t.Fatalf("big inverse mismatch %d != %d", expected.Uint64(), inverse)
+		}
+	}
+
+	if inverse := ModularMultiplicativeInverse(3, 7); inverse != 5 {
+		t.Fatalf("expected 5, got %d", inverse)
+	}
+	if _, ok := ModularInverse(6, 9); ok {
+		t.Fatal("expected no inverse")
+	}
+}
+
+func TestModularInverseBits(t *testing.T) {
+	t.Parallel()
+
+	// Previously computed by exhaustive search
+	if inverse := ModularMultiplicativeInverseBits(0x5DEECE66D, 48); inverse != 0xdfe05bcb1365 {
+		t.Fatalf("unexpected inverse %x", inverse)
+	}
+	if inverse := ModularMultiplicativeInverseFixed(uint32(0x015A4E35)); inverse != 0x2925141D {
+		t.Fatalf("unexpected inverse %x", inverse)
+	}
+	if inverse := ModularMultiplicativeInverseFixed(int32(-3)); inverse*-3 != 1 {
+		t.Fatalf("unexpected inverse %x", inverse)
+	}
+
+	for n := 1; n <= 64; n++ {
+		number := rand.Uint64() | 1
+		inverse, ok := ModularInverseBits(number, n)
+		mask := ^uint64(0) >> (64 - n)
+		if !ok || (number*inverse)&mask != 1&mask || inverse > mask {
+			t.Fatalf("wrong inverse %x of %x modulo 2^%d", inverse, number, n)
+		}
+
+		if expected := ModularInverseBitsBig(new(big.Int).SetUint64(number), n); expected.Uint64() != inverse {
+			t.Fatalf("big inverse mismatch %x != %x", expected.Uint64(), inverse)
+		}
+	}
+
+	if _, ok := ModularInverseBits(2, 32); ok {
+		t.Fatal("expected no inverse")
+	}
+
+	number := new(big.Int).Lsh(big.NewInt(0x5DEECE66D), 100)
+	number.Add(number, big.NewInt(1))
+	inverse := ModularInverseBitsBig(number, 160)
+	product := new(big.Int).Mul(number, inverse)
+	if product.And(product, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))).Cmp(big.NewInt(1)) != 0 {
+		t.Fatal("wrong big inverse")
+	}
+}
@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultTimeout = time.Second * 10
+
+var ErrTimeout = errors.New("request timed out")
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// Client Host side of the protocol. Requests are sequenced and matched against the echoed Header of responses
+type Client struct {
+	Transport Transport
+
+	// Timeout Maximum time for a request to be written and answered. Zero disables it
+	Timeout time.Duration
+
+	lock     sync.Mutex
+	sequence uint8
+}
+
+func NewClient(transport Transport) *Client {
+	return &Client{
+		Transport: transport,
+		Timeout:   DefaultTimeout,
+	}
+}
+
+// Execute Sends a request and waits for its response payload.
+// Responses to earlier requests that timed out are discarded.
+func (c *Client) Execute(command Command, payload []byte) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	header := Header{
+		Command:  command,
+		Sequence: SequenceFlag | c.sequence,
+	}
+	c.sequence = (c.sequence + 1) % SequenceModulo
+
+	var deadline time.Time
+	if c.Timeout > 0 {
+		deadline = time.Now().Add(c.Timeout)
+	}
+	if err := c.Transport.SetDeadline(deadline); err != nil {
+		return nil, c.wrapError(header, err)
+	}
+
+	if err := WriteFrame(c.Transport, header, payload); err != nil {
+		return nil, c.wrapError(header, err)
+	}
+
+	for {
+		responseHeader, response, err := ReadFrame(c.Transport)
+		if err != nil {
+			return nil, c.wrapError(header, err)
+		}
+
+		if responseHeader == header {
+			return response, nil
+		} else if responseHeader.Sequence == header.Sequence {
+			return nil, c.wrapError(header, fmt.Errorf("%w %s", ErrUnexpectedResponse, responseHeader))
+		}
+		// Stale response, keep reading
+	}
+}
+
+func (c *Client) wrapError(header Header, err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = ErrTimeout
+	}
+	return fmt.Errorf("%s: %w", header, err)
+}
@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func echoHandler(header Header, payload []byte) []byte {
+	return append([]byte(header.Command.String()+":"), payload...)
+}
+
+func TestClient_Execute(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(NewMockTransport(HandlerFunc(echoHandler)))
+
+	for i := 0; i < SequenceModulo*2; i++ {
+		response, err := client.Execute(CommandGetVersion, []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(response, []byte{'G', 'E', 'T', '_', 'V', 'E', 'R', 'S', 'I', 'O', 'N', ':', byte(i)}) != 0 {
+			t.Fatalf("unexpected response %q", response)
+		}
+	}
+}
+
+func TestClient_Execute_Sequence(t *testing.T) {
+	t.Parallel()
+
+	var sequences []uint8
+	client := NewClient(NewMockTransport(HandlerFunc(func(header Header, payload []byte) []byte {
+		sequences = append(sequences, header.Sequence)
+		return []byte{}
+	})))
+
+	for i := 0; i < SequenceModulo+1; i++ {
+		if _, err := client.Execute(CommandGetStatus, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, sequence := range sequences {
+		if sequence != SequenceFlag|uint8(i%SequenceModulo) {
+			t.Fatalf("unexpected sequence %02x at %d", sequence, i)
+		}
+	}
+}
+
+func TestClient_Execute_Timeout(t *testing.T) {
+	t.Parallel()
+
+	drop := true
+	transport := NewMockTransport(HandlerFunc(echoHandler))
+	transport.Intercept = func(frame []byte) []byte {
+		if drop {
+			return nil
+		}
+		return frame
+	}
+	client := NewClient(transport)
+
+	if _, err := client.Execute(CommandGetSerial, nil); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	drop = false
+	if _, err := client.Execute(CommandGetSerial, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Execute_Stale(t *testing.T) {
+	t.Parallel()
+
+	var delayed []byte
+	transport := NewMockTransport(HandlerFunc(echoHandler))
+	transport.Intercept = func(frame []byte) []byte {
+		// Deliver each response one request late
+		delayed, frame = frame, delayed
+		return frame
+	}
+	client := NewClient(transport)
+
+	if _, err := client.Execute(CommandGetSerial, []byte{1}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	transport.Intercept = func(frame []byte) []byte {
+		// Deliver the stale response first
+		return append(delayed, frame...)
+	}
+	response, err := client.Execute(CommandGetVersion, []byte{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(response, []byte("GET_VERSION:\x02")) != 0 {
+		t.Fatalf("unexpected response %q", response)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	t.Parallel()
+
+	header := Header{Command: CommandReadFlashArea, Sequence: SequenceFlag | 3}
+	frame := EncodeFrame(header, []byte{1, 2, 3})
+
+	decodedHeader, payload, err := ReadFrame(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decodedHeader != header || bytes.Compare(payload, []byte{1, 2, 3}) != 0 {
+		t.Fatal("frame does not match")
+	}
+
+	if _, _, err = ReadFrame(bytes.NewReader(frame[:len(frame)-1])); err == nil {
+		t.Fatal("expected error on truncated frame")
+	}
+}
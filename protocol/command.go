@@ -0,0 +1,68 @@
+package protocol
+
+import "fmt"
+
+type Command uint16
+
+// Commands as used by RadiaCode devices in normal operation
+const (
+	CommandGetStatus         = Command(0x0005)
+	CommandSetExchange       = Command(0x0007)
+	CommandGetVersion        = Command(0x000a)
+	CommandGetSerial         = Command(0x000b)
+	CommandFwImageGetInfo    = Command(0x0012)
+	CommandFwSignature       = Command(0x0101)
+	CommandReadHwConfig      = Command(0x0807)
+	CommandReadFlash         = Command(0x081c)
+	CommandReadVirtSfr       = Command(0x0824)
+	CommandWriteVirtSfr      = Command(0x0825)
+	CommandReadVirtString    = Command(0x0826)
+	CommandWriteVirtString   = Command(0x0827)
+	CommandReadVirtSfrBatch  = Command(0x082a)
+	CommandWriteVirtSfrBatch = Command(0x082b)
+	CommandSetTime           = Command(0x0a04)
+)
+
+// CommandReadFlashArea RD_FLASH_AREA, answered with a BorlandRand masked area. See firmware.DecodeReadFlashArea
+// Code has not been observed on device yet, and may change.
+const CommandReadFlashArea = Command(0xf001)
+
+// Bootloader firmware update commands. Responses are a single uint32 firmware.FlashStatus.
+// Codes have not been observed on device yet, and may change.
+const (
+	// CommandFlashUpdateBegin Starts an update session. Payload is uint8 Compressed, as in firmware.ASFileHeader
+	CommandFlashUpdateBegin = Command(0xf002)
+	// CommandFlashWriteBlock Programs one firmware.Block. Payload is encoded as in firmware files, see firmware.Block Bytes
+	CommandFlashWriteBlock = Command(0xf003)
+	// CommandFlashUpdateEnd Ends an update session
+	CommandFlashUpdateEnd = Command(0xf004)
+)
+
+var commandNames = map[Command]string{
+	CommandGetStatus:         "GET_STATUS",
+	CommandSetExchange:       "SET_EXCHANGE",
+	CommandGetVersion:        "GET_VERSION",
+	CommandGetSerial:         "GET_SERIAL",
+	CommandFwImageGetInfo:    "FW_IMAGE_GET_INFO",
+	CommandFwSignature:       "FW_SIGNATURE",
+	CommandReadHwConfig:      "RD_HW_CONFIG",
+	CommandReadFlash:         "RD_FLASH",
+	CommandReadVirtSfr:       "RD_VIRT_SFR",
+	CommandWriteVirtSfr:      "WR_VIRT_SFR",
+	CommandReadVirtString:    "RD_VIRT_STRING",
+	CommandWriteVirtString:   "WR_VIRT_STRING",
+	CommandReadVirtSfrBatch:  "RD_VIRT_SFR_BATCH",
+	CommandWriteVirtSfrBatch: "WR_VIRT_SFR_BATCH",
+	CommandSetTime:           "SET_TIME",
+	CommandReadFlashArea:     "RD_FLASH_AREA",
+	CommandFlashUpdateBegin:  "FLASH_UPDATE_BEGIN",
+	CommandFlashWriteBlock:   "FLASH_WRITE_BLOCK",
+	CommandFlashUpdateEnd:    "FLASH_UPDATE_END",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", uint16(c))
+}
@@ -0,0 +1,91 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const LengthSize = 4
+const HeaderSize = 4
+
+// MaxFrameSize Upper bound on a frame length prefix. Frames over this size are treated as corrupted
+const MaxFrameSize = 1 << 20
+
+// SequenceFlag Set on all sequence values sent by the host
+const SequenceFlag = 0x80
+
+// SequenceModulo Sequence numbers cycle through 0 to SequenceModulo-1
+const SequenceModulo = 32
+
+var ErrFrameTooLarge = errors.New("frame too large")
+var ErrFrameTooShort = errors.New("frame too short")
+
+// Header Each frame starts with this after the length prefix. Responses echo the header of the request
+type Header struct {
+	Command  Command
+	Reserved uint8
+	Sequence uint8
+}
+
+func (h Header) String() string {
+	return fmt.Sprintf("%s#%d", h.Command, h.Sequence&^SequenceFlag)
+}
+
+func (h Header) append(buf []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(h.Command))
+	return append(buf, h.Reserved, h.Sequence)
+}
+
+func decodeHeader(buf []byte) Header {
+	_ = buf[HeaderSize-1]
+	return Header{
+		Command:  Command(binary.LittleEndian.Uint16(buf)),
+		Reserved: buf[2],
+		Sequence: buf[3],
+	}
+}
+
+// EncodeFrame Encodes a frame as sent over a Transport
+//
+//	Length  uint32, size of Header and Payload
+//	Header  [4]byte
+//	Payload []byte
+func EncodeFrame(header Header, payload []byte) []byte {
+	buf := make([]byte, 0, LengthSize+HeaderSize+len(payload))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(HeaderSize+len(payload)))
+	buf = header.append(buf)
+	return append(buf, payload...)
+}
+
+// WriteFrame Writes a whole frame in a single Write call
+func WriteFrame(w io.Writer, header Header, payload []byte) error {
+	_, err := w.Write(EncodeFrame(header, payload))
+	return err
+}
+
+// ReadFrame Reads a whole frame, returning its Header and payload
+func ReadFrame(r io.Reader) (header Header, payload []byte, err error) {
+	var lengthBuf [LengthSize]byte
+	if _, err = io.ReadFull(r, lengthBuf[:]); err != nil {
+		return header, nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(lengthBuf[:])
+	if length > MaxFrameSize {
+		return header, nil, ErrFrameTooLarge
+	} else if length < HeaderSize {
+		return header, nil, ErrFrameTooShort
+	}
+
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return header, nil, err
+	}
+
+	return decodeHeader(buf), buf[HeaderSize:], nil
+}
@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"time"
+)
+
+// Handler Device side of the protocol
+type Handler interface {
+	// HandleRequest Returns the response payload for a request. A nil response sends no answer
+	HandleRequest(header Header, payload []byte) (response []byte)
+}
+
+type HandlerFunc func(header Header, payload []byte) (response []byte)
+
+func (f HandlerFunc) HandleRequest(header Header, payload []byte) (response []byte) {
+	return f(header, payload)
+}
+
+// MockTransport In-memory Transport that answers requests synchronously via a Handler.
+// As no data can arrive later, Read without pending data fails immediately with os.ErrDeadlineExceeded
+type MockTransport struct {
+	Handler Handler
+
+	// Intercept Optional fault injection hook, called with each encoded response frame.
+	// The returned data is queued instead, nil drops the response
+	Intercept func(frame []byte) []byte
+
+	lock     sync.Mutex
+	request  []byte
+	response []byte
+	deadline time.Time
+}
+
+func NewMockTransport(handler Handler) *MockTransport {
+	return &MockTransport{
+		Handler: handler,
+	}
+}
+
+func (t *MockTransport) Write(buf []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.deadlineExceeded() {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	t.request = append(t.request, buf...)
+
+	for len(t.request) >= LengthSize {
+		length := binary.LittleEndian.Uint32(t.request)
+		if length > MaxFrameSize || length < HeaderSize {
+			// Desynchronized, drop everything pending
+			t.request = nil
+			break
+		}
+		if len(t.request) < LengthSize+int(length) {
+			break
+		}
+
+		frame := t.request[LengthSize : LengthSize+length]
+		t.request = t.request[LengthSize+length:]
+
+		header := decodeHeader(frame)
+		response := t.Handler.HandleRequest(header, frame[HeaderSize:])
+		if response == nil {
+			continue
+		}
+
+		responseFrame := EncodeFrame(header, response)
+		if t.Intercept != nil {
+			responseFrame = t.Intercept(responseFrame)
+		}
+		t.response = append(t.response, responseFrame...)
+	}
+
+	return len(buf), nil
+}
+
+func (t *MockTransport) Read(buf []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.response) == 0 || t.deadlineExceeded() {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	n := copy(buf, t.response)
+	t.response = t.response[n:]
+	return n, nil
+}
+
+func (t *MockTransport) SetDeadline(deadline time.Time) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.deadline = deadline
+	return nil
+}
+
+func (t *MockTransport) deadlineExceeded() bool {
+	return !t.deadline.IsZero() && time.Now().After(t.deadline)
+}
@@ -0,0 +1,16 @@
+package protocol
+
+import (
+	"io"
+	"time"
+)
+
+// Transport Byte stream to a device, for example over USB or Bluetooth.
+// Compatible with net.Conn, so bridges over the network can be used directly.
+type Transport interface {
+	io.ReadWriter
+
+	// SetDeadline Sets the deadline for future Read and Write calls. A zero value disables the deadline.
+	// Calls past the deadline must fail with an error matching os.ErrDeadlineExceeded
+	SetDeadline(t time.Time) error
+}
@@ -0,0 +1,118 @@
+package spectrum
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/databuf"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV Writes metadata rows followed by one row per channel
+//
+//	start_time,2023-01-01T00:00:00Z
+//	live_time,60
+//	real_time,60
+//	calibration,a0,a1,a2
+//	channel,energy,counts
+//	0,a0,counts[0]
+//	...
+func WriteCSV(w io.Writer, m *Measurement) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"start_time", m.StartTime.Format(time.RFC3339Nano)},
+		{"live_time", strconv.FormatFloat(m.liveTime().Seconds(), 'f', -1, 64)},
+		{"real_time", strconv.FormatFloat(m.realTime().Seconds(), 'f', -1, 64)},
+		{"calibration", formatFloat32(m.Spectrum.Calibration[0]), formatFloat32(m.Spectrum.Calibration[1]), formatFloat32(m.Spectrum.Calibration[2])},
+		{"channel", "energy", "counts"},
+	}
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	for i, v := range m.Spectrum.Counts {
+		if err := writer.Write([]string{
+			strconv.Itoa(i),
+			strconv.FormatFloat(m.Spectrum.Energy(float64(i)), 'f', 3, 64),
+			strconv.FormatUint(uint64(v), 10),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+type jsonMeasurement struct {
+	StartTime time.Time `json:"start_time"`
+	// Duration Spectrum.Duration, which may differ from live and real time. If omitted, LiveTime is used
+	Duration     *float64   `json:"duration,omitempty"`
+	LiveTime     float64    `json:"live_time"`
+	RealTime     float64    `json:"real_time"`
+	Calibration  [3]float32 `json:"calibration"`
+	Counts       []uint32   `json:"counts"`
+	Manufacturer string     `json:"manufacturer,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	SerialNumber string     `json:"serial_number,omitempty"`
+}
+
+func (m *Measurement) MarshalJSON() ([]byte, error) {
+	duration := m.Spectrum.Duration.Seconds()
+	return json.Marshal(jsonMeasurement{
+		StartTime:    m.StartTime,
+		Duration:     &duration,
+		LiveTime:     m.liveTime().Seconds(),
+		RealTime:     m.realTime().Seconds(),
+		Calibration:  m.Spectrum.Calibration,
+		Counts:       m.Spectrum.Counts,
+		Manufacturer: m.Manufacturer,
+		Model:        m.Model,
+		SerialNumber: m.SerialNumber,
+	})
+}
+
+func (m *Measurement) UnmarshalJSON(buf []byte) error {
+	var v jsonMeasurement
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return err
+	}
+
+	duration := v.LiveTime
+	if v.Duration != nil {
+		duration = *v.Duration
+	}
+
+	*m = Measurement{
+		Spectrum: &databuf.Spectrum{
+			Duration:    parseSeconds(duration),
+			Calibration: v.Calibration,
+			Counts:      v.Counts,
+		},
+		StartTime:    v.StartTime,
+		LiveTime:     parseSeconds(v.LiveTime),
+		RealTime:     parseSeconds(v.RealTime),
+		Manufacturer: v.Manufacturer,
+		Model:        v.Model,
+		SerialNumber: v.SerialNumber,
+	}
+	return nil
+}
+
+func WriteJSON(w io.Writer, m *Measurement) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+func ReadJSON(r io.Reader) (*Measurement, error) {
+	m := &Measurement{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
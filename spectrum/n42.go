@@ -0,0 +1,124 @@
+package spectrum
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const N42Namespace = "http://physics.nist.gov/N42/2011/N42"
+
+type n42Document struct {
+	XMLName           xml.Name `xml:"RadInstrumentData"`
+	Namespace         string   `xml:"xmlns,attr"`
+	InstrumentInfo    n42InstrumentInformation
+	DetectorInfo      n42DetectorInformation
+	EnergyCalibration n42EnergyCalibration
+	Measurement       n42Measurement
+}
+
+type n42InstrumentInformation struct {
+	XMLName      xml.Name `xml:"RadInstrumentInformation"`
+	Id           string   `xml:"id,attr"`
+	Manufacturer string   `xml:"RadInstrumentManufacturerName"`
+	Identifier   string   `xml:"RadInstrumentIdentifier,omitempty"`
+	Model        string   `xml:"RadInstrumentModelName"`
+	ClassCode    string   `xml:"RadInstrumentClassCode"`
+}
+
+type n42DetectorInformation struct {
+	XMLName      xml.Name `xml:"RadDetectorInformation"`
+	Id           string   `xml:"id,attr"`
+	CategoryCode string   `xml:"RadDetectorCategoryCode"`
+	KindCode     string   `xml:"RadDetectorKindCode"`
+}
+
+type n42EnergyCalibration struct {
+	XMLName      xml.Name `xml:"EnergyCalibration"`
+	Id           string   `xml:"id,attr"`
+	Coefficients string   `xml:"CoefficientValues"`
+}
+
+type n42Measurement struct {
+	XMLName          xml.Name `xml:"RadMeasurement"`
+	Id               string   `xml:"id,attr"`
+	ClassCode        string   `xml:"MeasurementClassCode"`
+	StartDateTime    string   `xml:"StartDateTime"`
+	RealTimeDuration string   `xml:"RealTimeDuration"`
+	Spectrum         n42Spectrum
+}
+
+type n42Spectrum struct {
+	XMLName              xml.Name `xml:"Spectrum"`
+	Id                   string   `xml:"id,attr"`
+	DetectorReference    string   `xml:"radDetectorInformationReference,attr"`
+	CalibrationReference string   `xml:"energyCalibrationReference,attr"`
+	LiveTimeDuration     string   `xml:"LiveTimeDuration"`
+	ChannelData          n42ChannelData
+}
+
+type n42ChannelData struct {
+	XMLName         xml.Name `xml:"ChannelData"`
+	CompressionCode string   `xml:"compressionCode,attr"`
+	Data            string   `xml:",chardata"`
+}
+
+// WriteN42 Writes the measurement as an ANSI N42.42-2011 document, readable by InterSpec and others
+func WriteN42(w io.Writer, m *Measurement) error {
+	var coefficients, counts []string
+	for _, v := range m.Spectrum.Calibration {
+		coefficients = append(coefficients, formatFloat32(v))
+	}
+	for _, v := range m.Spectrum.Counts {
+		counts = append(counts, strconv.FormatUint(uint64(v), 10))
+	}
+
+	doc := n42Document{
+		Namespace: N42Namespace,
+		InstrumentInfo: n42InstrumentInformation{
+			Id:           "RadInstrumentInformation-1",
+			Manufacturer: m.Manufacturer,
+			Identifier:   m.SerialNumber,
+			Model:        m.Model,
+			ClassCode:    "Spectroscopic Personal Radiation Detector",
+		},
+		DetectorInfo: n42DetectorInformation{
+			Id:           "RadDetectorInformation-1",
+			CategoryCode: "Gamma",
+			KindCode:     "CsI",
+		},
+		EnergyCalibration: n42EnergyCalibration{
+			Id:           "EnergyCalibration-1",
+			Coefficients: strings.Join(coefficients, " "),
+		},
+		Measurement: n42Measurement{
+			Id:               "RadMeasurement-1",
+			ClassCode:        "Foreground",
+			StartDateTime:    m.StartTime.Format(time.RFC3339Nano),
+			RealTimeDuration: formatDuration(m.realTime()),
+			Spectrum: n42Spectrum{
+				Id:                   "Spectrum-1",
+				DetectorReference:    "RadDetectorInformation-1",
+				CalibrationReference: "EnergyCalibration-1",
+				LiveTimeDuration:     formatDuration(m.liveTime()),
+				ChannelData: n42ChannelData{
+					CompressionCode: "None",
+					Data:            strings.Join(counts, " "),
+				},
+			},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
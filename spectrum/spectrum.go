@@ -0,0 +1,63 @@
+package spectrum
+
+import (
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/databuf"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Measurement A decoded spectrum along with the metadata needed by analysis tools
+type Measurement struct {
+	Spectrum *databuf.Spectrum
+
+	StartTime time.Time
+
+	// LiveTime If zero, Spectrum.Duration is used
+	LiveTime time.Duration
+	// RealTime If zero, Spectrum.Duration is used
+	RealTime time.Duration
+
+	Manufacturer string
+	Model        string
+	SerialNumber string
+}
+
+func NewMeasurement(s *databuf.Spectrum, startTime time.Time) *Measurement {
+	return &Measurement{
+		Spectrum:     s,
+		StartTime:    startTime,
+		Manufacturer: "RadiaCode",
+		Model:        "RadiaCode-102",
+	}
+}
+
+func (m *Measurement) liveTime() time.Duration {
+	if m.LiveTime != 0 {
+		return m.LiveTime
+	}
+	return m.Spectrum.Duration
+}
+
+func (m *Measurement) realTime() time.Duration {
+	if m.RealTime != 0 {
+		return m.RealTime
+	}
+	return m.Spectrum.Duration
+}
+
+// formatFloat32 Shortest representation that parses back to the same float32
+func formatFloat32(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// formatDuration Encodes as xsd:duration
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%sS", strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+}
+
+// parseSeconds Inverse of Duration.Seconds, rounded to the nearest nanosecond
+func parseSeconds(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds * float64(time.Second)))
+}
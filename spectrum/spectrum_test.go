@@ -0,0 +1,123 @@
+package spectrum
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/databuf"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sampleMeasurement = &Measurement{
+	Spectrum: &databuf.Spectrum{
+		Duration:    time.Minute,
+		Calibration: [3]float32{-5.123, 2.4567, 0.00041},
+		Counts:      []uint32{0, 3, 7, 100, 4000000000, 1},
+	},
+	StartTime:    time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+	RealTime:     time.Minute + time.Second,
+	Manufacturer: "RadiaCode",
+	Model:        "RadiaCode-102",
+	SerialNumber: "RC-102-000123",
+}
+
+func TestWriteN42(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	if err := WriteN42(buf, sampleMeasurement); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+
+	var doc n42Document
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Measurement.Spectrum.ChannelData.Data != "0 3 7 100 4000000000 1" {
+		t.Fatalf("unexpected channel data %q", doc.Measurement.Spectrum.ChannelData.Data)
+	}
+	if doc.EnergyCalibration.Coefficients != "-5.123 2.4567 0.00041" {
+		t.Fatalf("unexpected coefficients %q", doc.EnergyCalibration.Coefficients)
+	}
+	if doc.Measurement.RealTimeDuration != "PT61S" || doc.Measurement.Spectrum.LiveTimeDuration != "PT60S" {
+		t.Fatal("unexpected durations")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	if err := WriteCSV(buf, sampleMeasurement); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(buf.String()))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 5+len(sampleMeasurement.Spectrum.Counts) {
+		t.Fatalf("unexpected row count %d", len(rows))
+	}
+	if slices.Compare(rows[3], []string{"calibration", "-5.123", "2.4567", "0.00041"}) != 0 {
+		t.Fatalf("unexpected calibration row %v", rows[3])
+	}
+	if slices.Compare(rows[5+4], []string{"4", "4.710", "4000000000"}) != 0 {
+		t.Fatalf("unexpected channel row %v", rows[5+4])
+	}
+}
+
+func TestJSON_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, sampleMeasurement); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if slices.Compare(m.Spectrum.Counts, sampleMeasurement.Spectrum.Counts) != 0 || m.Spectrum.Calibration != sampleMeasurement.Spectrum.Calibration {
+		t.Fatal("spectrum does not match")
+	}
+	if !m.StartTime.Equal(sampleMeasurement.StartTime) || m.liveTime() != sampleMeasurement.liveTime() || m.realTime() != sampleMeasurement.realTime() {
+		t.Fatal("metadata does not match")
+	}
+}
+
+func TestJSON_RoundTrip_Lossless(t *testing.T) {
+	t.Parallel()
+
+	measurement := *sampleMeasurement
+	measurement.StartTime = time.Date(2023, 1, 1, 12, 0, 0, 123456789, time.UTC)
+	measurement.LiveTime = 59*time.Second + 100*time.Millisecond
+	measurement.RealTime = 61*time.Second + 300*time.Millisecond
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, &measurement); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.StartTime.Equal(measurement.StartTime) {
+		t.Fatalf("start time %s does not match", m.StartTime)
+	}
+	if m.Spectrum.Duration != measurement.Spectrum.Duration || m.LiveTime != measurement.LiveTime || m.RealTime != measurement.RealTime {
+		t.Fatalf("durations %s, %s, %s do not match", m.Spectrum.Duration, m.LiveTime, m.RealTime)
+	}
+}
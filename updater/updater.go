@@ -0,0 +1,145 @@
+package updater
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/protocol"
+)
+
+const DefaultRetries = 3
+
+var ErrShortResponse = errors.New("short response")
+
+// Progress State of an Upload, reported after each acknowledged block
+type Progress struct {
+	// Entry Index of the firmware.Entry the block belongs to
+	Entry int
+	// Index Index of the block across all entries, as used to resume an Upload
+	Index int
+	// Total Number of blocks across all entries
+	Total int
+	// Retries Number of retries needed by this block
+	Retries int
+}
+
+// BlockError Returned by Upload when a block could not be programmed. Upload can be resumed from Index
+type BlockError struct {
+	Index  int
+	Status firmware.FlashStatus
+	Err    error
+}
+
+func (e *BlockError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("block %d: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("block %d: %s", e.Index, e.Status)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// Uploader Sends firmware blocks to a device bootloader over a protocol.Client
+type Uploader struct {
+	Client *protocol.Client
+
+	// Retries Maximum retries per request, on timeouts and transient flash failures
+	Retries int
+
+	// Progress Called after each acknowledged block, if set
+	Progress func(p Progress)
+}
+
+func NewUploader(client *protocol.Client) *Uploader {
+	return &Uploader{
+		Client:  client,
+		Retries: DefaultRetries,
+	}
+}
+
+// IsRetryable Whether a status can succeed when repeated.
+// FlashInitEr is not, as the update session must be started again first, which Upload does once per block
+func IsRetryable(status firmware.FlashStatus) bool {
+	switch status {
+	case firmware.FlashVerify, firmware.FlashErase, firmware.FlashProg, firmware.FlashInvalidCRC:
+		return true
+	default:
+		return false
+	}
+}
+
+// Upload Sends all blocks of fw in order, starting from block index start.
+// After an interruption, pass the BlockError Index to resume
+func (u *Uploader) Upload(fw *firmware.Firmware, start int) error {
+	var blocks []firmware.Block
+	var entries []int
+	for i, entry := range fw.Entries {
+		for _, block := range entry.Blocks {
+			blocks = append(blocks, block)
+			entries = append(entries, i)
+		}
+	}
+
+	if start < 0 || start > len(blocks) {
+		return fmt.Errorf("start block %d out of range", start)
+	}
+
+	beginPayload := []byte{fw.FileHeader.Compressed}
+	if status, _, err := u.execute(protocol.CommandFlashUpdateBegin, beginPayload); err != nil || status != firmware.FlashOK {
+		return &BlockError{Index: start, Status: status, Err: err}
+	}
+
+	for i := start; i < len(blocks); i++ {
+		status, retries, err := u.execute(protocol.CommandFlashWriteBlock, blocks[i].Bytes())
+		if err == nil && status == firmware.FlashInitEr {
+			// Device lost the update session, possibly after reset
+			if status, _, err = u.execute(protocol.CommandFlashUpdateBegin, beginPayload); err == nil && status == firmware.FlashOK {
+				status, retries, err = u.execute(protocol.CommandFlashWriteBlock, blocks[i].Bytes())
+			}
+		}
+		if err != nil || status != firmware.FlashOK {
+			return &BlockError{Index: i, Status: status, Err: err}
+		}
+
+		if u.Progress != nil {
+			u.Progress(Progress{
+				Entry:   entries[i],
+				Index:   i,
+				Total:   len(blocks),
+				Retries: retries,
+			})
+		}
+	}
+
+	if status, _, err := u.execute(protocol.CommandFlashUpdateEnd, nil); err != nil || status != firmware.FlashOK {
+		return &BlockError{Index: len(blocks), Status: status, Err: err}
+	}
+
+	return nil
+}
+
+// execute Sends a request answered by a FlashStatus, retrying on timeouts and retryable statuses
+func (u *Uploader) execute(command protocol.Command, payload []byte) (status firmware.FlashStatus, retries int, err error) {
+	for retries = 0; ; retries++ {
+		var response []byte
+		response, err = u.Client.Execute(command, payload)
+		if err == nil {
+			if len(response) < 4 {
+				return status, retries, ErrShortResponse
+			}
+			status = firmware.FlashStatus(binary.LittleEndian.Uint32(response))
+			if status == firmware.FlashOK || !IsRetryable(status) {
+				return status, retries, nil
+			}
+		} else if !errors.Is(err, protocol.ErrTimeout) {
+			return status, retries, err
+		}
+
+		if retries >= u.Retries {
+			return status, retries, err
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/emulator"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/encryption"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/firmware"
+	"git.gammaspectra.live/WeebDataHoarder/PhytonUtils/protocol"
+	"io"
+	"testing"
+)
+
+func testFirmware(t *testing.T, compressed bool) (*firmware.Firmware, []byte) {
+	code := make([]byte, firmware.DefaultBlockSize*5+0x123)
+	// Keep half of the code compressible
+	if _, err := io.ReadFull(rand.Reader, code[:len(code)/2]); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := &firmware.Firmware{}
+	if compressed {
+		fw.FileHeader.Compressed = 1
+	}
+
+	entry, err := firmware.NewEntry(code, firmware.BaseAddress, firmware.DefaultBlockSize, compressed, encryption.NewFlashKeyMaterial(&encryption.SecureRandomKeyGenerator{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Entries = append(fw.Entries, entry)
+
+	return fw, code
+}
+
+func verifyFlash(t *testing.T, device *emulator.Device, code []byte) {
+	flashData, status := device.Flash.Read(firmware.BaseAddress, len(code))
+	if status != firmware.FlashOK {
+		t.Fatal(status)
+	}
+	if bytes.Compare(flashData, code) != 0 {
+		t.Fatal("flash data does not match")
+	}
+}
+
+func TestUploader_Upload(t *testing.T) {
+	t.Parallel()
+
+	for _, compressed := range []bool{false, true} {
+		fw, code := testFirmware(t, compressed)
+		device := emulator.NewDevice(emulator.NewDefaultFlash())
+
+		var progress []Progress
+		uploader := NewUploader(protocol.NewClient(protocol.NewMockTransport(device)))
+		uploader.Progress = func(p Progress) {
+			progress = append(progress, p)
+		}
+
+		if err := uploader.Upload(fw, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		verifyFlash(t, device, code)
+
+		if len(progress) != len(fw.Entries[0].Blocks) || progress[len(progress)-1].Index != progress[len(progress)-1].Total-1 {
+			t.Fatalf("unexpected progress %v", progress)
+		}
+	}
+}
+
+func TestUploader_Upload_Retry(t *testing.T) {
+	t.Parallel()
+
+	fw, code := testFirmware(t, true)
+	device := emulator.NewDevice(emulator.NewDefaultFlash())
+
+	// Fail every other write with a transient error
+	var writes int
+	handler := protocol.HandlerFunc(func(header protocol.Header, payload []byte) []byte {
+		if header.Command == protocol.CommandFlashWriteBlock {
+			writes++
+			if writes%2 == 1 {
+				return binary.LittleEndian.AppendUint32(nil, uint32(firmware.FlashVerify))
+			}
+		}
+		return device.HandleRequest(header, payload)
+	})
+
+	var retries int
+	uploader := NewUploader(protocol.NewClient(protocol.NewMockTransport(handler)))
+	uploader.Progress = func(p Progress) {
+		retries += p.Retries
+	}
+
+	if err := uploader.Upload(fw, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyFlash(t, device, code)
+
+	if retries != len(fw.Entries[0].Blocks) {
+		t.Fatalf("expected %d retries, got %d", len(fw.Entries[0].Blocks), retries)
+	}
+}
+
+func TestUploader_Upload_Resume(t *testing.T) {
+	t.Parallel()
+
+	fw, code := testFirmware(t, false)
+	device := emulator.NewDevice(emulator.NewDefaultFlash())
+
+	const interruptAt = 3
+	var writes int
+	transport := protocol.NewMockTransport(device)
+	transport.Intercept = func(frame []byte) []byte {
+		// Device goes away after some blocks
+		if writes >= interruptAt {
+			return nil
+		}
+		if binary.LittleEndian.Uint16(frame[protocol.LengthSize:]) == uint16(protocol.CommandFlashWriteBlock) {
+			writes++
+		}
+		return frame
+	}
+
+	uploader := NewUploader(protocol.NewClient(transport))
+	err := uploader.Upload(fw, 0)
+
+	var blockErr *BlockError
+	if !errors.As(err, &blockErr) || !errors.Is(err, protocol.ErrTimeout) {
+		t.Fatalf("expected block timeout, got %v", err)
+	}
+	if blockErr.Index != interruptAt {
+		t.Fatalf("expected failure at block %d, got %d", interruptAt, blockErr.Index)
+	}
+
+	transport.Intercept = nil
+	if err = uploader.Upload(fw, blockErr.Index); err != nil {
+		t.Fatal(err)
+	}
+
+	verifyFlash(t, device, code)
+}
+
+func TestUploader_Upload_InitError(t *testing.T) {
+	t.Parallel()
+
+	if IsRetryable(firmware.FlashInitEr) {
+		t.Fatal("FlashInitEr must not be retried without a new session")
+	}
+
+	for _, lostSessions := range []int{1, 2} {
+		fw, code := testFirmware(t, true)
+		device := emulator.NewDevice(emulator.NewDefaultFlash())
+
+		// Device loses the update session on the second block
+		var begins, writes, failed int
+		handler := protocol.HandlerFunc(func(header protocol.Header, payload []byte) []byte {
+			switch header.Command {
+			case protocol.CommandFlashUpdateBegin:
+				begins++
+			case protocol.CommandFlashWriteBlock:
+				writes++
+				if writes >= 2 && failed < lostSessions {
+					failed++
+					return binary.LittleEndian.AppendUint32(nil, uint32(firmware.FlashInitEr))
+				}
+			}
+			return device.HandleRequest(header, payload)
+		})
+
+		uploader := NewUploader(protocol.NewClient(protocol.NewMockTransport(handler)))
+		err := uploader.Upload(fw, 0)
+
+		if lostSessions == 1 {
+			// Session is started again once, then the block is written
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyFlash(t, device, code)
+			if begins != 2 {
+				t.Fatalf("expected 2 update begins, got %d", begins)
+			}
+			continue
+		}
+
+		// Second loss fails the block without retries
+		var blockErr *BlockError
+		if !errors.As(err, &blockErr) || blockErr.Status != firmware.FlashInitEr || blockErr.Index != 1 {
+			t.Fatalf("expected FlashInitEr at block 1, got %v", err)
+		}
+		if begins != 2 || writes != 3 {
+			t.Fatalf("expected 2 update begins and 3 writes, got %d and %d", begins, writes)
+		}
+	}
+}
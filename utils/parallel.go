@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelRange Runs fn over [0, size) in chunks across workers, until done or ctx is cancelled.
+// If workers is zero or less, runtime.NumCPU() is used. progress, if set, is called with the number of items done so far.
+// Calls to progress are serialized and in increasing order, so it does not need to be safe for concurrent use
+func ParallelRange(ctx context.Context, size, chunkSize uint64, workers int, progress func(done, total uint64), fn func(start, end uint64)) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var next, done atomic.Uint64
+	var progressLock sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				start := next.Add(chunkSize) - chunkSize
+				if start >= size {
+					return
+				}
+				end := min(size, start+chunkSize)
+				fn(start, end)
+				if progress == nil {
+					done.Add(end - start)
+					continue
+				}
+				func() {
+					progressLock.Lock()
+					defer progressLock.Unlock()
+					progress(done.Add(end-start), size)
+				}()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if done.Load() == size {
+		return nil
+	}
+	return ctx.Err()
+}